@@ -62,6 +62,7 @@ func setFlags(cmd *cobra.Command) {
 	)
 	cmd.Flags().String("yurthub-image", latestYurtHubImage, "The yurthub image.")
 	cmd.Flags().String("yurt-tunnel-agent-image", latestYurtTunnelAgentImage, "The yurt-tunnel-agent image.")
+	cmd.Flags().String("sandbox-image", "", "The sandbox (pause) image of the container runtime, it's pulled along with the other images when set.")
 	cmd.Flags().BoolP("deploy-yurttunnel", "t", false, "If set, yurt-tunnel-agent will be deployed.")
 	cmd.Flags().String("ignore-preflight-errors", "", "A list of checks whose errors will be shown as warnings. "+
 		"Example: 'isprivilegeduser,imagepull'.Value 'all' ignores errors from all checks.",
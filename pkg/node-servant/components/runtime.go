@@ -17,6 +17,7 @@ limitations under the License.
 package components
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	goruntime "runtime"
@@ -41,6 +42,42 @@ type ContainerRuntimeForImage interface {
 	IsDocker() bool
 	PullImage(image string) error
 	ImageExists(image string) (bool, error)
+	ListRuntimeHandlers() ([]string, error)
+	DefaultRuntimeHandler() (string, error)
+	RuntimeConfig() (*RuntimeConfig, error)
+	Status() ([]RuntimeCondition, error)
+	Version() (*RuntimeVersion, error)
+}
+
+const (
+	// RuntimeReady means the runtime is up and ready to accept basic containers
+	RuntimeReady = "RuntimeReady"
+	// NetworkReady means the runtime network is up and ready to accept containers which require network
+	NetworkReady = "NetworkReady"
+)
+
+// RuntimeCondition is a condition reported by the CRI Status API
+type RuntimeCondition struct {
+	Type    string `json:"type"`
+	Status  bool   `json:"status"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// RuntimeConfig is the configuration reported by the container runtime
+type RuntimeConfig struct {
+	// CgroupDriver is the cgroup driver used by the runtime, systemd or cgroupfs
+	CgroupDriver string
+}
+
+// RuntimeVersion is the version reported by the container runtime
+type RuntimeVersion struct {
+	// RuntimeName is the name of the runtime, e.g. containerd
+	RuntimeName string
+	// RuntimeVersion is the version of the runtime itself, e.g. v1.6.8
+	RuntimeVersion string
+	// RuntimeAPIVersion is the CRI API version served by the runtime, e.g. v1
+	RuntimeAPIVersion string
 }
 
 // CRIRuntime is a struct that interfaces with the CRI
@@ -127,6 +164,218 @@ func (runtime *DockerRuntime) ImageExists(image string) (bool, error) {
 	return err == nil, nil
 }
 
+// ListRuntimeHandlers returns the runtime handlers configured in the CRI runtime
+func (runtime *CRIRuntime) ListRuntimeHandlers() ([]string, error) {
+	info, err := runtime.info()
+	if err != nil {
+		return nil, err
+	}
+	return info.runtimeHandlers(), nil
+}
+
+// ListRuntimeHandlers returns the runtimes registered in the Docker daemon
+func (runtime *DockerRuntime) ListRuntimeHandlers() ([]string, error) {
+	out, err := runtime.exec.Command("docker", "info", "--format", "{{json .Runtimes}}").CombinedOutput()
+	if err != nil {
+		return nil, errors.Wrapf(err, "output: %s, error", out)
+	}
+	runtimes := map[string]json.RawMessage{}
+	if err := json.Unmarshal(out, &runtimes); err != nil {
+		return nil, errors.Wrap(err, "failed to parse docker runtimes")
+	}
+	handlers := make([]string, 0, len(runtimes))
+	for name := range runtimes {
+		handlers = append(handlers, name)
+	}
+	return handlers, nil
+}
+
+// DefaultRuntimeHandler returns the runtime handler used when no RuntimeClass is specified
+func (runtime *CRIRuntime) DefaultRuntimeHandler() (string, error) {
+	info, err := runtime.info()
+	if err != nil {
+		return "", err
+	}
+	if info.Config.Containerd.DefaultRuntimeName == "" {
+		return "", errors.New("default runtime handler is not reported by the CRI runtime")
+	}
+	return info.Config.Containerd.DefaultRuntimeName, nil
+}
+
+// DefaultRuntimeHandler returns the default runtime of the Docker daemon
+func (runtime *DockerRuntime) DefaultRuntimeHandler() (string, error) {
+	out, err := runtime.exec.Command("docker", "info", "--format", "{{.DefaultRuntime}}").CombinedOutput()
+	if err != nil {
+		return "", errors.Wrapf(err, "output: %s, error", out)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// RuntimeConfig returns the configuration reported by the CRI RuntimeConfig API,
+// runtimes that don't implement it return an error
+func (runtime *CRIRuntime) RuntimeConfig() (*RuntimeConfig, error) {
+	out, err := runtime.exec.Command("crictl", "-r", runtime.criSocket, "runtime-config").Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "output: %s, error", out)
+	}
+	return parseCRIRuntimeConfig(out)
+}
+
+// RuntimeConfig returns the configuration reported by the Docker daemon
+func (runtime *DockerRuntime) RuntimeConfig() (*RuntimeConfig, error) {
+	out, err := runtime.exec.Command("docker", "info", "--format", "{{.CgroupDriver}}").CombinedOutput()
+	if err != nil {
+		return nil, errors.Wrapf(err, "output: %s, error", out)
+	}
+	return &RuntimeConfig{CgroupDriver: strings.TrimSpace(string(out))}, nil
+}
+
+// Status returns the conditions reported by the CRI Status API
+func (runtime *CRIRuntime) Status() ([]RuntimeCondition, error) {
+	info, err := runtime.info()
+	if err != nil {
+		return nil, err
+	}
+	if len(info.Status.Conditions) == 0 {
+		return nil, errors.New("runtime conditions are not reported by the CRI runtime")
+	}
+	return info.Status.Conditions, nil
+}
+
+// Status reports RuntimeReady according to whether the Docker daemon responds,
+// Docker doesn't report the network condition
+func (runtime *DockerRuntime) Status() ([]RuntimeCondition, error) {
+	out, err := runtime.exec.Command("docker", "info", "--format", "{{.ServerVersion}}").CombinedOutput()
+	if err != nil {
+		return []RuntimeCondition{{Type: RuntimeReady, Status: false, Reason: "DockerDaemonNotReady", Message: strings.TrimSpace(string(out))}}, nil
+	}
+	return []RuntimeCondition{{Type: RuntimeReady, Status: true}}, nil
+}
+
+// Version returns the version reported by the CRI Version API
+func (runtime *CRIRuntime) Version() (*RuntimeVersion, error) {
+	out, err := runtime.exec.Command("crictl", "-r", runtime.criSocket, "version").Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "output: %s, error", out)
+	}
+	return parseCRIVersion(string(out))
+}
+
+// Version returns the version of the Docker daemon, RuntimeAPIVersion is the Docker API
+// version as Docker doesn't serve the CRI API
+func (runtime *DockerRuntime) Version() (*RuntimeVersion, error) {
+	out, err := runtime.exec.Command("docker", "version", "--format", "{{.Server.Version}} {{.Server.APIVersion}}").CombinedOutput()
+	if err != nil {
+		return nil, errors.Wrapf(err, "output: %s, error", out)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 2 {
+		return nil, errors.Errorf("failed to parse docker version from output %q", out)
+	}
+	return &RuntimeVersion{RuntimeName: "docker", RuntimeVersion: fields[0], RuntimeAPIVersion: fields[1]}, nil
+}
+
+// parseCRIVersion parses the output of `crictl version`, e.g.
+//
+//	Version:  0.1.0
+//	RuntimeName:  containerd
+//	RuntimeVersion:  v1.6.8
+//	RuntimeApiVersion:  v1
+func parseCRIVersion(out string) (*RuntimeVersion, error) {
+	version := &RuntimeVersion{}
+	for _, line := range strings.Split(out, "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(parts[1])
+		switch strings.TrimSpace(parts[0]) {
+		case "RuntimeName":
+			version.RuntimeName = value
+		case "RuntimeVersion":
+			version.RuntimeVersion = value
+		case "RuntimeApiVersion":
+			version.RuntimeAPIVersion = value
+		}
+	}
+	if version.RuntimeAPIVersion == "" {
+		return nil, errors.Errorf("runtime API version is not found in crictl version output %q", out)
+	}
+	return version, nil
+}
+
+// criRuntimeConfig is the output of `crictl runtime-config`
+type criRuntimeConfig struct {
+	Linux *struct {
+		CgroupDriver string `json:"cgroupDriver"`
+	} `json:"linux"`
+}
+
+func parseCRIRuntimeConfig(out []byte) (*RuntimeConfig, error) {
+	config := &criRuntimeConfig{}
+	if err := json.Unmarshal(out, config); err != nil {
+		return nil, errors.Wrap(err, "failed to parse crictl runtime-config")
+	}
+	if config.Linux == nil {
+		return nil, errors.New("linux runtime config is not reported by the CRI runtime")
+	}
+	// SYSTEMD is the zero value of the CgroupDriver enum, so it may be omitted
+	driver := strings.ToLower(config.Linux.CgroupDriver)
+	if driver == "" {
+		driver = "systemd"
+	}
+	return &RuntimeConfig{CgroupDriver: driver}, nil
+}
+
+// criInfo is the subset of `crictl info` output used to inspect the CRI runtime
+type criInfo struct {
+	Status struct {
+		Conditions []RuntimeCondition `json:"conditions"`
+	} `json:"status"`
+	RuntimeHandlers []struct {
+		Name string `json:"name"`
+	} `json:"runtimeHandlers"`
+	Config struct {
+		Containerd struct {
+			DefaultRuntimeName string                     `json:"defaultRuntimeName"`
+			Runtimes           map[string]json.RawMessage `json:"runtimes"`
+		} `json:"containerd"`
+	} `json:"config"`
+}
+
+// info runs `crictl info` and parses its output
+func (runtime *CRIRuntime) info() (*criInfo, error) {
+	out, err := runtime.exec.Command("crictl", "-r", runtime.criSocket, "info").Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "output: %s, error", out)
+	}
+	return parseCRIInfo(out)
+}
+
+func parseCRIInfo(out []byte) (*criInfo, error) {
+	info := &criInfo{}
+	if err := json.Unmarshal(out, info); err != nil {
+		return nil, errors.Wrap(err, "failed to parse crictl info")
+	}
+	return info, nil
+}
+
+// runtimeHandlers prefers the handlers reported by the CRI status, and falls back to
+// the runtimes in the containerd config for runtimes that don't report them.
+func (info *criInfo) runtimeHandlers() []string {
+	var handlers []string
+	if len(info.RuntimeHandlers) != 0 {
+		for _, h := range info.RuntimeHandlers {
+			handlers = append(handlers, h.Name)
+		}
+		return handlers
+	}
+	for name := range info.Config.Containerd.Runtimes {
+		handlers = append(handlers, name)
+	}
+	return handlers
+}
+
 // detectCRISocketImpl is separated out only for test purposes, DON'T call it directly, use DetectCRISocket instead
 func detectCRISocketImpl(isSocket func(string) bool) (string, error) {
 	foundCRISockets := []string{}
@@ -0,0 +1,149 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestCRIInfoRuntimeHandlers(t *testing.T) {
+	tests := []struct {
+		name     string
+		info     string
+		expected []string
+	}{
+		{
+			name:     "handlers reported by cri status",
+			info:     `{"runtimeHandlers":[{"name":"runc"},{"name":"kata"}],"config":{"containerd":{"runtimes":{"runc":{}}}}}`,
+			expected: []string{"kata", "runc"},
+		},
+		{
+			name:     "handlers from containerd config",
+			info:     `{"status":{},"config":{"containerd":{"runtimes":{"runc":{"runtimeType":"io.containerd.runc.v2"},"nvidia":{}}}}}`,
+			expected: []string{"nvidia", "runc"},
+		},
+		{
+			name:     "no handlers",
+			info:     `{"status":{}}`,
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := parseCRIInfo([]byte(tt.info))
+			if err != nil {
+				t.Fatalf("failed to parse cri info: %v", err)
+			}
+			handlers := info.runtimeHandlers()
+			sort.Strings(handlers)
+			if !reflect.DeepEqual(handlers, tt.expected) {
+				t.Errorf("expected handlers %v, got %v", tt.expected, handlers)
+			}
+		})
+	}
+}
+
+func TestCRIInfoDefaultRuntimeName(t *testing.T) {
+	info, err := parseCRIInfo([]byte(`{"config":{"containerd":{"defaultRuntimeName":"runc","runtimes":{"runc":{}}}}}`))
+	if err != nil {
+		t.Fatalf("failed to parse cri info: %v", err)
+	}
+	if info.Config.Containerd.DefaultRuntimeName != "runc" {
+		t.Errorf("expected default runtime runc, got %q", info.Config.Containerd.DefaultRuntimeName)
+	}
+}
+
+func TestParseCRIRuntimeConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		out       string
+		expected  string
+		expectErr bool
+	}{
+		{name: "systemd", out: `{"linux":{"cgroupDriver":"SYSTEMD"}}`, expected: "systemd"},
+		{name: "cgroupfs", out: `{"linux":{"cgroupDriver":"CGROUPFS"}}`, expected: "cgroupfs"},
+		{name: "zero value omitted", out: `{"linux":{}}`, expected: "systemd"},
+		{name: "not reported", out: `{}`, expectErr: true},
+		{name: "invalid output", out: `not json`, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := parseCRIRuntimeConfig([]byte(tt.out))
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("expected error %v, got %v", tt.expectErr, err)
+			}
+			if err == nil && config.CgroupDriver != tt.expected {
+				t.Errorf("expected cgroup driver %s, got %s", tt.expected, config.CgroupDriver)
+			}
+		})
+	}
+}
+
+func TestCRIInfoStatus(t *testing.T) {
+	info, err := parseCRIInfo([]byte(`{"status":{"conditions":[{"type":"RuntimeReady","status":true,"reason":"","message":""},{"type":"NetworkReady","status":false,"reason":"NetworkPluginNotReady","message":"cni plugin not initialized"}]}}`))
+	if err != nil {
+		t.Fatalf("failed to parse cri info: %v", err)
+	}
+	expected := []RuntimeCondition{
+		{Type: RuntimeReady, Status: true},
+		{Type: NetworkReady, Status: false, Reason: "NetworkPluginNotReady", Message: "cni plugin not initialized"},
+	}
+	if !reflect.DeepEqual(info.Status.Conditions, expected) {
+		t.Errorf("expected conditions %v, got %v", expected, info.Status.Conditions)
+	}
+}
+
+func TestParseCRIVersion(t *testing.T) {
+	tests := []struct {
+		name      string
+		out       string
+		expected  *RuntimeVersion
+		expectErr bool
+	}{
+		{
+			name:     "containerd",
+			out:      "Version:  0.1.0\nRuntimeName:  containerd\nRuntimeVersion:  v1.6.8\nRuntimeApiVersion:  v1\n",
+			expected: &RuntimeVersion{RuntimeName: "containerd", RuntimeVersion: "v1.6.8", RuntimeAPIVersion: "v1"},
+		},
+		{
+			name:     "cri-o with alpha api",
+			out:      "Version:  0.1.0\nRuntimeName:  cri-o\nRuntimeVersion:  1.20.0\nRuntimeApiVersion:  v1alpha2\n",
+			expected: &RuntimeVersion{RuntimeName: "cri-o", RuntimeVersion: "1.20.0", RuntimeAPIVersion: "v1alpha2"},
+		},
+		{
+			name:      "api version not reported",
+			out:       "Version:  0.1.0\n",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, err := parseCRIVersion(tt.out)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("expected error %v, got %v", tt.expectErr, err)
+			}
+			if err == nil && !reflect.DeepEqual(version, tt.expected) {
+				t.Errorf("expected version %+v, got %+v", tt.expected, version)
+			}
+		})
+	}
+}
@@ -35,6 +35,7 @@ type Options struct {
 	KubeadmConfPaths      []string
 	YurthubImage          string
 	YurttunnelAgentImage  string
+	SandboxImage          string
 	DeployTunnel          bool
 	IgnorePreflightErrors sets.String
 
@@ -54,9 +55,16 @@ func (o *Options) GetImageList() []string {
 	if o.DeployTunnel {
 		imgs = append(imgs, o.YurttunnelAgentImage)
 	}
+	if o.SandboxImage != "" {
+		imgs = append(imgs, o.SandboxImage)
+	}
 	return imgs
 }
 
+func (o *Options) GetSandboxImage() string {
+	return o.SandboxImage
+}
+
 func (o *Options) GetImagePullPolicy() v1.PullPolicy {
 	return o.ImagePullPolicy
 }
@@ -102,6 +110,12 @@ func (o *Options) Complete(flags *pflag.FlagSet) error {
 	}
 	o.YurttunnelAgentImage = yurttunnelAgentImage
 
+	sandboxImage, err := flags.GetString("sandbox-image")
+	if err != nil {
+		return err
+	}
+	o.SandboxImage = sandboxImage
+
 	dt, err := flags.GetBool("deploy-yurttunnel")
 	if err != nil {
 		return err
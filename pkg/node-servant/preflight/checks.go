@@ -43,6 +43,65 @@ import (
 // Error defines struct for communicating error messages generated by preflight-convert-convert checks
 type Error struct {
 	Msg string
+	// Failures holds the results of the checks that failed, in the order they ran.
+	Failures []CheckResult
+}
+
+// CheckResult holds the warnings and errors reported by a single check.
+type CheckResult struct {
+	Name     string
+	Warnings []error
+	Errors   []error
+	// Codes holds the reason codes of the Errors that carry one, in the same order.
+	Codes []string
+	// Evidence holds the raw system state behind the Errors, only collected by RunChecksWithEvidence.
+	Evidence string
+}
+
+const (
+	// ReasonSwapEnabled is reported when swap is enabled while it's not tolerated.
+	ReasonSwapEnabled = "SWAP_ENABLED"
+	// ReasonPortInUse is reported when a port required by OpenYurt components is already in use.
+	ReasonPortInUse = "PORT_IN_USE"
+	// ReasonImageCheckFailed is reported when the existence of an image can't be checked.
+	ReasonImageCheckFailed = "IMAGE_CHECK_FAILED"
+	// ReasonImagePullFailed is reported when an image can't be pulled.
+	ReasonImagePullFailed = "IMAGE_PULL_FAILED"
+	// ReasonSandboxImagePullFailed is reported when the sandbox (pause) image can't be pulled,
+	// which makes every pod fail to start.
+	ReasonSandboxImagePullFailed = "SANDBOX_IMAGE_PULL_FAILED"
+	// ReasonUnsupportedPullPolicy is reported when the image pull policy is unknown.
+	ReasonUnsupportedPullPolicy = "UNSUPPORTED_PULL_POLICY"
+)
+
+// CheckError is an error reported by a check together with a stable machine readable code,
+// so that failures can be matched without parsing the message.
+type CheckError struct {
+	Code    string
+	Message string
+}
+
+// Error implements the standard error interface, only the message is returned
+// so that the plain-text output of RunChecks stays unchanged.
+func (e *CheckError) Error() string {
+	return e.Message
+}
+
+// newCheckError returns a CheckError with the given code and formatted message.
+func newCheckError(code, format string, args ...interface{}) *CheckError {
+	return &CheckError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// errorCodes returns the codes of the errors in errs that carry one.
+func errorCodes(errs []error) []string {
+	var codes []string
+	for _, err := range errs {
+		var checkErr *CheckError
+		if errors.As(err, &checkErr) && checkErr.Code != "" {
+			codes = append(codes, checkErr.Code)
+		}
+	}
+	return codes
 }
 
 // Error implements the standard error interface
@@ -139,6 +198,10 @@ func (jc JobExistCheck) Name() string {
 	return fmt.Sprintf("JobExist-%s", jc.Prefix)
 }
 
+func (jc JobExistCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"prefix": jc.Prefix}
+}
+
 func (jc JobExistCheck) Check() (warnings, errorList []error) {
 	klog.V(1).Infoln("validating convert jobs")
 	var invalidJobNames []string
@@ -208,6 +271,10 @@ func (fac FileExistingCheck) Name() string {
 	return fmt.Sprintf("FileExisting-%s", strings.Replace(fac.Path, "/", "-", -1))
 }
 
+func (fac FileExistingCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"path": fac.Path}
+}
+
 func (fac FileExistingCheck) Check() (warnings, errorList []error) {
 	klog.V(1).Infof("validating the existence of file %s", fac.Path)
 
@@ -231,6 +298,10 @@ func (foc FileAtLeastOneExistingCheck) Name() string {
 	return fmt.Sprintf("FileAtLeastOneExistingCheck-%s", foc.Paths[0])
 }
 
+func (foc FileAtLeastOneExistingCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"paths": foc.Paths}
+}
+
 func (foc FileAtLeastOneExistingCheck) Check() (warnings, errorList []error) {
 	klog.V(1).Infof("validating if at least one file exists in the file list: %s", foc.Paths)
 	for _, path := range foc.Paths {
@@ -256,6 +327,10 @@ func (dac DirExistingCheck) Name() string {
 	return fmt.Sprintf("DirExisting-%s", strings.Replace(dac.Path, "/", "-", -1))
 }
 
+func (dac DirExistingCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"path": dac.Path}
+}
+
 // Check validates if a directory exists or does not empty.
 func (dac DirExistingCheck) Check() (warnings, errorList []error) {
 	klog.V(1).Infof("validating the existence of directory %s", dac.Path)
@@ -290,12 +365,31 @@ func (poc PortOpenCheck) Name() string {
 	return fmt.Sprintf("Port-%d", poc.port)
 }
 
+func (poc PortOpenCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"port": poc.port}
+}
+
+// Evidence returns the sockets listening on the port, as listed in /proc/net/tcp and /proc/net/tcp6.
+func (poc PortOpenCheck) Evidence() string {
+	var lines []string
+	for _, path := range []string{procNetTCP, procNetTCP6} {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		for _, line := range procNetSocketLines(string(content), "tcp", poc.port) {
+			lines = append(lines, path+": "+line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
 func (poc PortOpenCheck) Check() (warnings, errorList []error) {
 	klog.V(1).Infof("validating availability of port %d", poc.port)
 
 	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", poc.port))
 	if err != nil {
-		errorList = []error{errors.Errorf("Port %d is in use", poc.port)}
+		errorList = []error{newCheckError(ReasonPortInUse, "Port %d is in use", poc.port)}
 	}
 	if ln != nil {
 		if err = ln.Close(); err != nil {
@@ -311,12 +405,21 @@ type ImagePullCheck struct {
 	runtime         components.ContainerRuntimeForImage
 	imageList       []string
 	imagePullPolicy v1.PullPolicy
+	// sandboxImage is the sandbox (pause) image of the runtime, its pull failure is reported distinctly.
+	sandboxImage string
 }
 
 func (ImagePullCheck) Name() string {
 	return "ImagePull"
 }
 
+func (ipc ImagePullCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"imageList":       ipc.imageList,
+		"imagePullPolicy": string(ipc.imagePullPolicy),
+	}
+}
+
 func (ipc ImagePullCheck) Check() (warnings, errorList []error) {
 	policy := ipc.imagePullPolicy
 	klog.V(1).Infof("using image pull policy: %s", policy)
@@ -332,17 +435,21 @@ func (ipc ImagePullCheck) Check() (warnings, errorList []error) {
 				continue
 			}
 			if err != nil {
-				errorList = append(errorList, errors.Wrapf(err, "failed to check if image %s exists", image))
+				errorList = append(errorList, newCheckError(ReasonImageCheckFailed, "failed to check if image %s exists: %v", image, err))
 			}
 			fallthrough // Proceed with pulling the image if it does not exist
 		case v1.PullAlways:
 			klog.V(1).Infof("pulling: %s", image)
 			if err := ipc.runtime.PullImage(image); err != nil {
-				errorList = append(errorList, errors.Wrapf(err, "failed to pull image %s", image))
+				if image == ipc.sandboxImage {
+					errorList = append(errorList, newCheckError(ReasonSandboxImagePullFailed, "failed to pull sandbox image %s, every pod will fail to start, please check the registry auth and mirror config of the container runtime: %v", image, err))
+					continue
+				}
+				errorList = append(errorList, newCheckError(ReasonImagePullFailed, "failed to pull image %s: %v", image, err))
 			}
 		default:
 			// If the policy is unknown return early with an error
-			errorList = append(errorList, errors.Errorf("unsupported pull policy %q", policy))
+			errorList = append(errorList, newCheckError(ReasonUnsupportedPullPolicy, "unsupported pull policy %q", policy))
 			return warnings, errorList
 		}
 	}
@@ -355,29 +462,19 @@ func RunConvertNodeChecks(o KubePathOperator, ignorePreflightErrors sets.String,
 		return err
 	}
 
-	checks := []Checker{
-		FileAtLeastOneExistingCheck{Paths: o.GetKubeadmConfPaths(), Label: "KubeadmConfig"},
-		FileExistingCheck{Path: o.GetKubeAdmFlagsEnvFile(), Label: "KubeAdmFlagsEnv"},
-		DirExistingCheck{Path: KubernetesDir},
-		DirExistingCheck{Path: KubeletPkiDir},
-		PortOpenCheck{port: YurtHubProxySecurePort},
-		PortOpenCheck{port: YurtHubProxyPort},
-		PortOpenCheck{port: YurtHubPort},
-	}
-
-	if deployTunnel {
-		checks = append(checks, PortOpenCheck{port: YurttunnelAgentPort})
+	checks, err := NewChecks(CheckConfig{Profile: ProfileConvert, KubePaths: o, DeployTunnel: deployTunnel})
+	if err != nil {
+		return err
 	}
 	return RunChecks(checks, os.Stderr, ignorePreflightErrors)
-
 }
 
 // RunRootCheckOnly initializes checks slice of structs and call RunChecks
 func RunRootCheckOnly(ignorePreflightErrors sets.String) error {
-	checks := []Checker{
-		IsPrivilegedUserCheck{},
+	checks, err := NewChecks(CheckConfig{Profile: ProfileRoot})
+	if err != nil {
+		return err
 	}
-
 	return RunChecks(checks, os.Stderr, ignorePreflightErrors)
 }
 
@@ -388,20 +485,95 @@ func RunPullImagesCheck(o ImageOperator, ignorePreflightErrors sets.String) erro
 		return err
 	}
 
-	checks := []Checker{
-		ImagePullCheck{runtime: containerRuntime, imageList: o.GetImageList(), imagePullPolicy: o.GetImagePullPolicy()},
+	var sandboxImage string
+	if so, ok := o.(sandboxImageOperator); ok {
+		sandboxImage = so.GetSandboxImage()
+	}
+
+	checks, err := NewChecks(CheckConfig{
+		Profile:         ProfileImages,
+		Runtime:         containerRuntime,
+		Images:          o.GetImageList(),
+		ImagePullPolicy: o.GetImagePullPolicy(),
+		SandboxImage:    sandboxImage,
+	})
+	if err != nil {
+		return err
 	}
 	return RunChecks(checks, os.Stderr, ignorePreflightErrors)
 }
 
+// ProgressFunc is called by RunChecksWithProgress before and after each check, with the number of
+// checks completed so far, the total number of checks and the name of the current check.
+type ProgressFunc func(completed, total int, currentName string)
+
+// DependentChecker is implemented by checks that are pointless when some other checks fail,
+// e.g. a check querying the container runtime after the check of the runtime itself.
+type DependentChecker interface {
+	Checker
+	// DependsOn returns the names of the checks this check depends on.
+	DependsOn() []string
+}
+
 // RunChecks runs each check, displays it's warnings/errors, and once all
 // are processed will exit if any errors occurred.
+// Checks with the same name are run only once, the first one is kept.
 func RunChecks(checks []Checker, ww io.Writer, ignorePreflightErrors sets.String) error {
+	return runChecks(checks, ww, ignorePreflightErrors, nil, runOptions{})
+}
+
+// RunChecksWithProgress is like RunChecks, but reports the progress of the run to progress, e.g. for a progress bar.
+func RunChecksWithProgress(checks []Checker, ww io.Writer, ignorePreflightErrors sets.String, progress ProgressFunc) error {
+	return runChecks(checks, ww, ignorePreflightErrors, progress, runOptions{})
+}
+
+// RunChecksWithDependencies is like RunChecksWithProgress, but orders the checks so that each check runs
+// after the checks it depends on, and skips the checks whose dependencies produced errors
+// (ignored or not), rather than running them into confusing secondary errors. Skipped checks
+// are reported as warnings. Dependencies that are not in checks are ignored.
+func RunChecksWithDependencies(checks []Checker, ww io.Writer, ignorePreflightErrors sets.String, progress ProgressFunc) error {
+	ordered, err := sortChecksByDependencies(checks)
+	if err != nil {
+		return err
+	}
+	return runChecks(ordered, ww, ignorePreflightErrors, progress, runOptions{skipOnDependencyFailure: true})
+}
+
+// RunChecksWithEvidence is like RunChecksWithProgress, but additionally collects the evidence of the failed
+// checks implementing EvidenceChecker into the Failures of the returned Error, e.g. for support bundles.
+func RunChecksWithEvidence(checks []Checker, ww io.Writer, ignorePreflightErrors sets.String, progress ProgressFunc) error {
+	return runChecks(checks, ww, ignorePreflightErrors, progress, runOptions{collectEvidence: true})
+}
+
+// runOptions tweaks how runChecks runs the checks.
+type runOptions struct {
+	skipOnDependencyFailure bool
+	collectEvidence         bool
+}
+
+func runChecks(checks []Checker, ww io.Writer, ignorePreflightErrors sets.String, progress ProgressFunc, opts runOptions) error {
 	var errsBuffer bytes.Buffer
+	var failures []CheckResult
+	failed := sets.NewString()
 
-	for _, c := range checks {
+	checks = dedupeChecks(checks)
+	if progress == nil {
+		progress = func(int, int, string) {}
+	}
+	for i, c := range checks {
 		name := c.Name()
-		warnings, errs := c.Check()
+		progress(i, len(checks), name)
+		var warnings, errs []error
+		if dep := failedDependency(c, failed); opts.skipOnDependencyFailure && dep != "" {
+			warnings = []error{errors.Errorf("skipped (dependency %s failed)", dep)}
+			failed.Insert(name)
+		} else {
+			warnings, errs = c.Check()
+			if len(errs) != 0 {
+				failed.Insert(name)
+			}
+		}
+		progress(i+1, len(checks), name)
 
 		if setHasItemOrAll(ignorePreflightErrors, name) {
 			// Decrease severity of errors to warnings for this check
@@ -415,13 +587,108 @@ func RunChecks(checks []Checker, ww io.Writer, ignorePreflightErrors sets.String
 		for _, i := range errs {
 			errsBuffer.WriteString(fmt.Sprintf("\t[ERROR %s]: %v\n", name, i.Error()))
 		}
+		if len(errs) != 0 {
+			result := CheckResult{Name: name, Warnings: warnings, Errors: errs, Codes: errorCodes(errs)}
+			if opts.collectEvidence {
+				result.Evidence = collectEvidence(c)
+			}
+			failures = append(failures, result)
+		}
 	}
 	if errsBuffer.Len() > 0 {
-		return &Error{Msg: errsBuffer.String()}
+		return &Error{Msg: errsBuffer.String(), Failures: failures}
 	}
 	return nil
 }
 
+// RunChecksWithPostChecks runs the main checks first, and only when all of them
+// have passed runs the post checks, which rely on the state set up by the main phase
+// (e.g. ClusterDNSCheck needs the node joined and CoreDNS running).
+func RunChecksWithPostChecks(checks, postChecks []Checker, ww io.Writer, ignorePreflightErrors sets.String) error {
+	if err := RunChecks(checks, ww, ignorePreflightErrors); err != nil {
+		return err
+	}
+	return RunChecks(postChecks, ww, ignorePreflightErrors)
+}
+
+// dedupeChecks drops the checks whose names are already taken by earlier checks, so that
+// the checks added twice by profiles are neither reported nor run twice.
+func dedupeChecks(checks []Checker) []Checker {
+	seen := sets.NewString()
+	deduped := make([]Checker, 0, len(checks))
+	for _, c := range checks {
+		name := c.Name()
+		if seen.Has(name) {
+			klog.V(1).Infof("dropping duplicated check %s", name)
+			continue
+		}
+		seen.Insert(name)
+		deduped = append(deduped, c)
+	}
+	return deduped
+}
+
+// failedDependency returns the first dependency of c in failed, or an empty string.
+func failedDependency(c Checker, failed sets.String) string {
+	dc, ok := c.(DependentChecker)
+	if !ok {
+		return ""
+	}
+	for _, dep := range dc.DependsOn() {
+		if failed.Has(dep) {
+			return dep
+		}
+	}
+	return ""
+}
+
+// sortChecksByDependencies orders checks so that every check comes after the checks it depends on,
+// the original order is kept otherwise. An error is returned if the dependencies form a cycle.
+func sortChecksByDependencies(checks []Checker) ([]Checker, error) {
+	names := sets.NewString()
+	for _, c := range checks {
+		names.Insert(c.Name())
+	}
+
+	ordered := make([]Checker, 0, len(checks))
+	placed := sets.NewString()
+	remaining := checks
+	for len(remaining) != 0 {
+		var next []Checker
+		for _, c := range remaining {
+			if dependenciesPlaced(c, names, placed) {
+				ordered = append(ordered, c)
+				placed.Insert(c.Name())
+			} else {
+				next = append(next, c)
+			}
+		}
+		if len(next) == len(remaining) {
+			var cyclic []string
+			for _, c := range next {
+				cyclic = append(cyclic, c.Name())
+			}
+			return nil, errors.Errorf("[preflight] dependencies of checks %v form a cycle", cyclic)
+		}
+		remaining = next
+	}
+	return ordered, nil
+}
+
+// dependenciesPlaced returns true if all the dependencies of c that are in names are placed.
+func dependenciesPlaced(c Checker, names, placed sets.String) bool {
+	dc, ok := c.(DependentChecker)
+	if !ok {
+		return true
+	}
+	for _, dep := range dc.DependsOn() {
+		if names.Has(dep) && !placed.Has(dep) {
+			return false
+		}
+	}
+	return true
+}
+
 // setHasItemOrAll is helper function that return true if item is present in the set (case insensitive) or special key 'all' is present
 func setHasItemOrAll(s sets.String, item string) bool {
 	if s.Has("all") || s.Has(strings.ToLower(item)) {
@@ -0,0 +1,718 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+	bootstrapapi "k8s.io/cluster-bootstrap/token/api"
+	bootstraputil "k8s.io/cluster-bootstrap/token/util"
+	"k8s.io/klog/v2"
+
+	"github.com/openyurtio/openyurt/pkg/util/pubkeypin"
+	"github.com/openyurtio/openyurt/pkg/yurtadm/constants"
+)
+
+const (
+	discoveryEndpointTimeout = 5 * time.Second
+
+	defaultBootstrapTokenClockSkew = time.Minute
+
+	defaultWebhookDialTimeout = 3 * time.Second
+	defaultWebhookServicePort = 443
+)
+
+// defaultLeftoverStatePaths are the state files left by a previous kubeadm or yurtadm run.
+var defaultLeftoverStatePaths = []string{
+	filepath.Join(constants.KubeletWorkdir, kubeletConfigFile),
+	filepath.Join(KubernetesDir, "bootstrap-kubelet.conf"),
+	filepath.Join(KubernetesDir, constants.KubeletKubeConfigFileName),
+	constants.YurtHubBootstrapConfig,
+	filepath.Join(constants.StaticPodPath, constants.YurthubYamlName),
+}
+
+// serviceCollisionNamespaces are the namespaces whose services are checked against the node name.
+var serviceCollisionNamespaces = []string{metav1.NamespaceSystem, metav1.NamespaceDefault}
+
+// ExistingClusterMembershipCheck verifies that the node doesn't carry the membership artifacts
+// (admin.conf, kubelet.conf, pki/ca.crt) of another cluster. The CA found in the artifacts is
+// matched against CACertHashes, the public key hashes (sha256:<hex>) of the intended cluster CA.
+type ExistingClusterMembershipCheck struct {
+	CACertHashes []string
+	// KubernetesDir defaults to /etc/kubernetes.
+	KubernetesDir string
+}
+
+func (ExistingClusterMembershipCheck) Name() string {
+	return "ExistingClusterMembership"
+}
+
+func (ecc ExistingClusterMembershipCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"caCertHashes":  ecc.CACertHashes,
+		"kubernetesDir": ecc.KubernetesDir,
+	}
+}
+
+func (ecc ExistingClusterMembershipCheck) Check() (warnings, errorList []error) {
+	dir := ecc.KubernetesDir
+	if dir == "" {
+		dir = KubernetesDir
+	}
+	klog.V(1).Infof("validating the node is not a member of another cluster by artifacts in %s", dir)
+
+	pins := pubkeypin.NewSet()
+	if err := pins.Allow(ecc.CACertHashes...); err != nil {
+		return nil, []error{errors.Wrap(err, "invalid CA cert hashes")}
+	}
+
+	artifacts := []string{
+		filepath.Join(dir, "admin.conf"),
+		filepath.Join(dir, "kubelet.conf"),
+		filepath.Join(dir, "pki", "ca.crt"),
+	}
+	for _, artifact := range artifacts {
+		if _, err := os.Stat(artifact); err != nil {
+			continue
+		}
+
+		cas, err := loadClusterCAs(artifact)
+		if err != nil {
+			warnings = append(warnings, errors.Wrapf(err, "found cluster membership artifact %s, but failed to load its CA", artifact))
+			continue
+		}
+		if pins.Empty() {
+			warnings = append(warnings, errors.Errorf("found cluster membership artifact %s, but no CA cert hash is given to match it", artifact))
+			continue
+		}
+		if err := pins.CheckAny(cas); err != nil {
+			errorList = append(errorList, errors.Errorf("%s belongs to another cluster with CA %s, please reset the node first", artifact, pubkeypin.Hash(cas[0])))
+		}
+	}
+	return warnings, errorList
+}
+
+// loadClusterCAs loads the CA certificates from a kubeconfig or a PEM encoded certificate file.
+func loadClusterCAs(path string) ([]*x509.Certificate, error) {
+	if filepath.Ext(path) == ".crt" {
+		return certutil.CertsFromFile(path)
+	}
+
+	config, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cas []*x509.Certificate
+	for name, cluster := range config.Clusters {
+		data := cluster.CertificateAuthorityData
+		if len(data) == 0 && cluster.CertificateAuthority != "" {
+			if data, err = os.ReadFile(cluster.CertificateAuthority); err != nil {
+				return nil, errors.Wrapf(err, "failed to read CA of cluster %s", name)
+			}
+		}
+		certs, err := certutil.ParseCertsPEM(data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse CA of cluster %s", name)
+		}
+		cas = append(cas, certs...)
+	}
+	if len(cas) == 0 {
+		return nil, errors.New("no CA found")
+	}
+	return cas, nil
+}
+
+// CAValidityWindowCheck verifies that the local time is within the validity window of the cluster CA,
+// otherwise the certificates issued to the node appear not yet valid or already expired, which fails
+// the join in confusing ways. It's skipped if the CA is not available.
+type CAValidityWindowCheck struct {
+	// CAPath is a PEM encoded CA certificate (.crt) or a kubeconfig embedding the CA,
+	// defaults to /etc/kubernetes/pki/ca.crt.
+	CAPath string
+}
+
+func (CAValidityWindowCheck) Name() string {
+	return "CAValidityWindow"
+}
+
+func (cvc CAValidityWindowCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"caPath": cvc.CAPath}
+}
+
+func (cvc CAValidityWindowCheck) Check() (warnings, errorList []error) {
+	path := cvc.CAPath
+	if path == "" {
+		path = filepath.Join(KubernetesDir, "pki", "ca.crt")
+	}
+	klog.V(1).Infof("validating local time against the validity window of CA in %s", path)
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		klog.V(1).Infof("%s doesn't exist, skipping", path)
+		return nil, nil
+	}
+	cas, err := loadClusterCAs(path)
+	if err != nil {
+		return nil, []error{errors.Wrapf(err, "failed to load CA from %s", path)}
+	}
+	return nil, validateCAValidityWindow(cas, time.Now())
+}
+
+// validateCAValidityWindow returns an error for every CA whose validity window doesn't contain now.
+func validateCAValidityWindow(cas []*x509.Certificate, now time.Time) (errorList []error) {
+	for _, ca := range cas {
+		if now.Before(ca.NotBefore) {
+			errorList = append(errorList, errors.Errorf("local time %s is before the start %s of the validity of CA %q, the local clock is probably behind, please synchronize it",
+				now.UTC().Format(time.RFC3339), ca.NotBefore.UTC().Format(time.RFC3339), ca.Subject.CommonName))
+		} else if now.After(ca.NotAfter) {
+			errorList = append(errorList, errors.Errorf("local time %s is after the expiry %s of CA %q, the local clock is probably ahead or the CA has expired",
+				now.UTC().Format(time.RFC3339), ca.NotAfter.UTC().Format(time.RFC3339), ca.Subject.CommonName))
+		}
+	}
+	return errorList
+}
+
+// PKIPermissionsCheck verifies that the private keys in Dir are not readable by group or others,
+// and warns on files not owned by root. It's skipped if Dir doesn't exist.
+type PKIPermissionsCheck struct {
+	// Dir defaults to /etc/kubernetes/pki.
+	Dir string
+}
+
+func (PKIPermissionsCheck) Name() string {
+	return "PKIPermissions"
+}
+
+func (ppc PKIPermissionsCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"dir": ppc.Dir}
+}
+
+func (ppc PKIPermissionsCheck) Check() (warnings, errorList []error) {
+	dir := ppc.Dir
+	if dir == "" {
+		dir = filepath.Join(KubernetesDir, "pki")
+	}
+	klog.V(1).Infof("validating the permissions of files in %s", dir)
+
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		klog.V(1).Infof("%s doesn't exist, skipping", dir)
+		return nil, nil
+	}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		if uid, ok := fileOwner(info); ok && uid != 0 {
+			warnings = append(warnings, errors.Errorf("%s is owned by uid %d, expected root", path, uid))
+		}
+		if filepath.Ext(path) == ".key" && info.Mode().Perm()&0077 != 0 {
+			errorList = append(errorList, errors.Errorf("private key %s has mode %04o, it must not be accessible by group or others", path, info.Mode().Perm()))
+		}
+		return nil
+	})
+	if err != nil {
+		errorList = append(errorList, errors.Wrapf(err, "failed to walk %s", dir))
+	}
+	return warnings, errorList
+}
+
+// ServiceAccountKeyCheck verifies that the service account signing key pair used by a new
+// control-plane node exists and that the public key matches the private key, otherwise the
+// tokens issued by the node can't be validated by the others.
+type ServiceAccountKeyCheck struct {
+	// KeyPath defaults to /etc/kubernetes/pki/sa.key.
+	KeyPath string
+	// PubPath defaults to /etc/kubernetes/pki/sa.pub.
+	PubPath string
+}
+
+func (ServiceAccountKeyCheck) Name() string {
+	return "ServiceAccountKey"
+}
+
+func (sac ServiceAccountKeyCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"keyPath": sac.KeyPath,
+		"pubPath": sac.PubPath,
+	}
+}
+
+func (sac ServiceAccountKeyCheck) Check() (warnings, errorList []error) {
+	keyPath, pubPath := sac.KeyPath, sac.PubPath
+	if keyPath == "" {
+		keyPath = filepath.Join(KubernetesDir, "pki", "sa.key")
+	}
+	if pubPath == "" {
+		pubPath = filepath.Join(KubernetesDir, "pki", "sa.pub")
+	}
+	klog.V(1).Infof("validating service account key pair %s and %s", keyPath, pubPath)
+
+	key, err := keyutil.PrivateKeyFromFile(keyPath)
+	if err != nil {
+		return nil, []error{errors.Wrapf(err, "unable to load service account private key %s", keyPath)}
+	}
+	pubs, err := keyutil.PublicKeysFromFile(pubPath)
+	if err != nil {
+		return nil, []error{errors.Wrapf(err, "unable to load service account public key %s", pubPath)}
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, []error{errors.Errorf("service account private key %s is of unsupported type %T", keyPath, key)}
+	}
+	derived, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok {
+		return nil, []error{errors.Errorf("service account private key %s is of unsupported type %T", keyPath, key)}
+	}
+	for _, pub := range pubs {
+		if derived.Equal(pub) {
+			return nil, nil
+		}
+	}
+	return nil, []error{errors.Errorf("service account public key %s doesn't match private key %s", pubPath, keyPath)}
+}
+
+// DiscoveryEndpointCheck verifies that the API server in the bootstrap kubeconfig is reachable,
+// and that the certificate chain it presents validates against the CA in the kubeconfig, which
+// catches intercepting proxies and stale CAs before join.
+type DiscoveryEndpointCheck struct {
+	KubeconfigPath string
+}
+
+func (DiscoveryEndpointCheck) Name() string {
+	return "DiscoveryEndpoint"
+}
+
+func (dec DiscoveryEndpointCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"kubeconfigPath": dec.KubeconfigPath}
+}
+
+func (dec DiscoveryEndpointCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infof("validating discovery endpoint in %s", dec.KubeconfigPath)
+
+	config, err := clientcmd.LoadFromFile(dec.KubeconfigPath)
+	if err != nil {
+		return nil, []error{errors.Wrapf(err, "failed to load bootstrap kubeconfig %s", dec.KubeconfigPath)}
+	}
+	cluster := currentCluster(config)
+	if cluster == nil {
+		return nil, []error{errors.Errorf("no cluster found in bootstrap kubeconfig %s", dec.KubeconfigPath)}
+	}
+
+	server, err := url.Parse(cluster.Server)
+	if err != nil || server.Host == "" {
+		return nil, []error{errors.Errorf("invalid server %q in bootstrap kubeconfig %s", cluster.Server, dec.KubeconfigPath)}
+	}
+	caData := cluster.CertificateAuthorityData
+	if len(caData) == 0 && cluster.CertificateAuthority != "" {
+		if caData, err = os.ReadFile(cluster.CertificateAuthority); err != nil {
+			return nil, []error{errors.Wrapf(err, "failed to read CA %s", cluster.CertificateAuthority)}
+		}
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caData) {
+		return nil, []error{errors.Errorf("no valid CA found in bootstrap kubeconfig %s", dec.KubeconfigPath)}
+	}
+
+	address := server.Host
+	if server.Port() == "" {
+		address = net.JoinHostPort(server.Hostname(), "443")
+	}
+	dialer := &net.Dialer{Timeout: discoveryEndpointTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{RootCAs: pool, ServerName: server.Hostname()})
+	if err != nil {
+		var certErr x509.UnknownAuthorityError
+		if errors.As(err, &certErr) {
+			return nil, []error{errors.Errorf("certificate presented by %s is not signed by the CA in bootstrap kubeconfig %s, the CA may be stale or the connection is intercepted", address, dec.KubeconfigPath)}
+		}
+		return nil, []error{errors.Wrapf(err, "unable to establish tls connection to %s", address)}
+	}
+	conn.Close()
+	return nil, nil
+}
+
+// currentCluster returns the cluster of the current context, or any cluster if there is no current context.
+func currentCluster(config *clientcmdapi.Config) *clientcmdapi.Cluster {
+	if ctx, ok := config.Contexts[config.CurrentContext]; ok {
+		if cluster, ok := config.Clusters[ctx.Cluster]; ok {
+			return cluster
+		}
+	}
+	for _, cluster := range config.Clusters {
+		return cluster
+	}
+	return nil
+}
+
+// HostnameServiceCollisionCheck warns when the node name matches the name of a Service in kube-system
+// or default, which may make DNS resolution ambiguous in some setups. It's skipped without a client.
+type HostnameServiceCollisionCheck struct {
+	client kubernetes.Interface
+	// NodeName defaults to the hostname.
+	NodeName string
+}
+
+func (HostnameServiceCollisionCheck) Name() string {
+	return "HostnameServiceCollision"
+}
+
+func (hsc HostnameServiceCollisionCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"nodeName": hsc.NodeName}
+}
+
+func (hsc HostnameServiceCollisionCheck) Check() (warnings, errorList []error) {
+	if hsc.client == nil {
+		return nil, nil
+	}
+	nodeName := hsc.NodeName
+	if nodeName == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return []error{errors.Wrap(err, "unable to get hostname")}, nil
+		}
+		nodeName = strings.ToLower(hostname)
+	}
+	klog.V(1).Infof("validating node name %s doesn't collide with service names", nodeName)
+
+	for _, ns := range serviceCollisionNamespaces {
+		svcs, err := hsc.client.CoreV1().Services(ns).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			warnings = append(warnings, errors.Wrapf(err, "unable to list services in namespace %s", ns))
+			continue
+		}
+		for _, svc := range svcs.Items {
+			if svc.Name == nodeName {
+				warnings = append(warnings, errors.Errorf("node name %s is the same as the name of service %s/%s, which may make dns resolution ambiguous", nodeName, ns, svc.Name))
+			}
+		}
+	}
+	return warnings, nil
+}
+
+// NodeIPUniquenessCheck warns when NodeIP is already reported by another Node in the cluster, e.g. when
+// DHCP reassigned the IP of a node that is still registered, which misroutes traffic to the node.
+// The Node of this node itself is ignored, as it's registered when re-running for convert or rejoin.
+// It's skipped without a client.
+type NodeIPUniquenessCheck struct {
+	client kubernetes.Interface
+	NodeIP string
+	// NodeName defaults to the hostname.
+	NodeName string
+}
+
+func (NodeIPUniquenessCheck) Name() string {
+	return "NodeIPUniqueness"
+}
+
+func (nuc NodeIPUniquenessCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"nodeIP":   nuc.NodeIP,
+		"nodeName": nuc.NodeName,
+	}
+}
+
+func (nuc NodeIPUniquenessCheck) Check() (warnings, errorList []error) {
+	if nuc.client == nil {
+		return nil, nil
+	}
+	nodeName := nuc.NodeName
+	if nodeName == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return []error{errors.Wrap(err, "unable to get hostname")}, nil
+		}
+		nodeName = strings.ToLower(hostname)
+	}
+	klog.V(1).Infof("validating node ip %s is not registered by another node than %s", nuc.NodeIP, nodeName)
+
+	nodeIP := net.ParseIP(nuc.NodeIP)
+	if nodeIP == nil {
+		return nil, []error{errors.Errorf("invalid node ip %q", nuc.NodeIP)}
+	}
+	nodes, err := nuc.client.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return []error{errors.Wrap(err, "unable to list nodes")}, nil
+	}
+	for _, node := range nodes.Items {
+		if node.Name == nodeName {
+			continue
+		}
+		for _, addr := range node.Status.Addresses {
+			if addr.Type != v1.NodeInternalIP && addr.Type != v1.NodeExternalIP {
+				continue
+			}
+			if ip := net.ParseIP(addr.Address); ip != nil && ip.Equal(nodeIP) {
+				warnings = append(warnings, errors.Errorf("node ip %s is already registered by node %s as its %s, please delete the stale node or use another ip", nuc.NodeIP, node.Name, addr.Type))
+			}
+		}
+	}
+	return warnings, nil
+}
+
+// BootstrapTokenCheck verifies the format of the bootstrap token, and when the token secret can be
+// read, that the local time is not before the creation of the token by more than ClockSkew, as a
+// lagging clock makes the node reject the certificates issued for it as not yet valid.
+type BootstrapTokenCheck struct {
+	client kubernetes.Interface
+	Token  string
+	// ClockSkew defaults to 1m.
+	ClockSkew time.Duration
+}
+
+func (BootstrapTokenCheck) Name() string {
+	return "BootstrapToken"
+}
+
+func (btc BootstrapTokenCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"clockSkew": btc.ClockSkew.String()}
+}
+
+func (btc BootstrapTokenCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infoln("validating bootstrap token")
+
+	substrs := bootstraputil.BootstrapTokenRegexp.FindStringSubmatch(btc.Token)
+	if len(substrs) != 3 {
+		return nil, []error{errors.Errorf("the bootstrap token is not of the form %q", bootstrapapi.BootstrapTokenPattern)}
+	}
+	if btc.client == nil {
+		return nil, nil
+	}
+	secretName := bootstrapapi.BootstrapTokenSecretPrefix + substrs[1]
+	secret, err := btc.client.CoreV1().Secrets(metav1.NamespaceSystem).Get(context.TODO(), secretName, metav1.GetOptions{})
+	if err != nil {
+		// the bootstrap token itself is usually not allowed to read its secret
+		klog.V(1).Infof("unable to read secret %s, skipping validity start check: %v", secretName, err)
+		return nil, nil
+	}
+	skew := btc.ClockSkew
+	if skew == 0 {
+		skew = defaultBootstrapTokenClockSkew
+	}
+	if err := validateTokenValidityStart(secret.CreationTimestamp.Time, time.Now(), skew); err != nil {
+		return nil, []error{err}
+	}
+	return nil, nil
+}
+
+// validateTokenValidityStart returns an error if now is before created by more than skew.
+func validateTokenValidityStart(created, now time.Time, skew time.Duration) error {
+	if now.Add(skew).Before(created) {
+		return errors.Errorf("local time %s is %s before the creation %s of the bootstrap token, the local clock lags, please synchronize it (e.g. with chrony or systemd-timesyncd)",
+			now.UTC().Format(time.RFC3339), created.Sub(now).Round(time.Second), created.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// WebhookReachabilityCheck warns when the admission webhooks of the cluster are not reachable from
+// the node, a control-plane node whose API server can't reach them blocks every admitted request.
+// Webhooks backed by a service are dialed at the endpoints of the service. It's skipped without a client.
+type WebhookReachabilityCheck struct {
+	client kubernetes.Interface
+	// Timeout defaults to 3s.
+	Timeout time.Duration
+}
+
+func (WebhookReachabilityCheck) Name() string {
+	return "WebhookReachability"
+}
+
+func (wrc WebhookReachabilityCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"timeout": wrc.Timeout.String()}
+}
+
+func (wrc WebhookReachabilityCheck) Check() (warnings, errorList []error) {
+	if wrc.client == nil {
+		return nil, nil
+	}
+	timeout := wrc.Timeout
+	if timeout == 0 {
+		timeout = defaultWebhookDialTimeout
+	}
+	klog.V(1).Infoln("validating admission webhooks are reachable")
+
+	webhooks, err := listWebhooks(wrc.client)
+	if err != nil {
+		return []error{err}, nil
+	}
+	for _, wh := range webhooks {
+		addresses, err := webhookAddresses(wrc.client, wh.config)
+		if err != nil {
+			warnings = append(warnings, errors.Wrapf(err, "unable to resolve webhook %s", wh.name))
+			continue
+		}
+		if !anyReachable(addresses, timeout) {
+			warnings = append(warnings, errors.Errorf("webhook %s is not reachable at %v, the requests it admits will fail", wh.name, addresses))
+		}
+	}
+	return warnings, nil
+}
+
+// admissionWebhook is a validating or mutating webhook, named after its configuration.
+type admissionWebhook struct {
+	name   string
+	config admissionregistrationv1.WebhookClientConfig
+}
+
+// listWebhooks returns the validating and mutating webhooks of the cluster.
+func listWebhooks(client kubernetes.Interface) ([]admissionWebhook, error) {
+	var webhooks []admissionWebhook
+	validating, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list validating webhook configurations")
+	}
+	for _, c := range validating.Items {
+		for _, wh := range c.Webhooks {
+			webhooks = append(webhooks, admissionWebhook{name: c.Name + "/" + wh.Name, config: wh.ClientConfig})
+		}
+	}
+	mutating, err := client.AdmissionregistrationV1().MutatingWebhookConfigurations().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list mutating webhook configurations")
+	}
+	for _, c := range mutating.Items {
+		for _, wh := range c.Webhooks {
+			webhooks = append(webhooks, admissionWebhook{name: c.Name + "/" + wh.Name, config: wh.ClientConfig})
+		}
+	}
+	return webhooks, nil
+}
+
+// webhookAddresses returns the host:port addresses serving the webhook, i.e. the host of its URL,
+// or the endpoints of its service matching the service port.
+func webhookAddresses(client kubernetes.Interface, config admissionregistrationv1.WebhookClientConfig) ([]string, error) {
+	if config.URL != nil {
+		u, err := url.Parse(*config.URL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid url %q", *config.URL)
+		}
+		if u.Port() == "" {
+			return []string{net.JoinHostPort(u.Hostname(), "443")}, nil
+		}
+		return []string{u.Host}, nil
+	}
+	if config.Service == nil {
+		return nil, errors.New("neither url nor service is set")
+	}
+
+	ref := config.Service
+	port := int32(defaultWebhookServicePort)
+	if ref.Port != nil {
+		port = *ref.Port
+	}
+	svc, err := client.CoreV1().Services(ref.Namespace).Get(context.TODO(), ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get service %s/%s", ref.Namespace, ref.Name)
+	}
+	portName, found := "", false
+	for _, p := range svc.Spec.Ports {
+		if p.Port == port {
+			portName, found = p.Name, true
+			break
+		}
+	}
+	if !found {
+		return nil, errors.Errorf("service %s/%s has no port %d", ref.Namespace, ref.Name, port)
+	}
+	endpoints, err := client.CoreV1().Endpoints(ref.Namespace).Get(context.TODO(), ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get endpoints of service %s/%s", ref.Namespace, ref.Name)
+	}
+
+	var addresses []string
+	for _, subset := range endpoints.Subsets {
+		for _, p := range subset.Ports {
+			if p.Name != portName {
+				continue
+			}
+			for _, addr := range subset.Addresses {
+				addresses = append(addresses, net.JoinHostPort(addr.IP, strconv.Itoa(int(p.Port))))
+			}
+		}
+	}
+	if len(addresses) == 0 {
+		return nil, errors.Errorf("service %s/%s has no ready endpoints", ref.Namespace, ref.Name)
+	}
+	return addresses, nil
+}
+
+// anyReachable returns true if a tcp connection can be established to any of addresses.
+func anyReachable(addresses []string, timeout time.Duration) bool {
+	for _, address := range addresses {
+		conn, err := net.DialTimeout("tcp", address, timeout)
+		if err == nil {
+			conn.Close()
+			return true
+		}
+	}
+	return false
+}
+
+// LeftoverStateCheck warns about the state files left by a previous (failed) run, which may confuse
+// a fresh run. It doesn't error, as some of them are safely overwritten.
+type LeftoverStateCheck struct {
+	// Paths defaults to the known kubeadm and yurtadm state files.
+	Paths []string
+}
+
+func (LeftoverStateCheck) Name() string {
+	return "LeftoverState"
+}
+
+func (lsc LeftoverStateCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"paths": lsc.Paths}
+}
+
+func (lsc LeftoverStateCheck) Check() (warnings, errorList []error) {
+	paths := lsc.Paths
+	if len(paths) == 0 {
+		paths = defaultLeftoverStatePaths
+	}
+	klog.V(1).Infof("validating no state is left in %v", paths)
+
+	var found []string
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			found = append(found, path)
+		}
+	}
+	if len(found) != 0 {
+		warnings = append(warnings, errors.Errorf("found state left by a previous run: %s, please consider running 'yurtadm reset' first", strings.Join(found, ", ")))
+	}
+	return warnings, nil
+}
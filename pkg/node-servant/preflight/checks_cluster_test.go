@@ -0,0 +1,451 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+
+	"github.com/openyurtio/openyurt/pkg/util/pubkeypin"
+)
+
+func newTestCA(t *testing.T, name string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	cert, err := certutil.NewSelfSignedCACert(certutil.Config{CommonName: name}, key)
+	if err != nil {
+		t.Fatalf("failed to generate CA: %v", err)
+	}
+	return cert
+}
+
+func TestExistingClusterMembershipCheck(t *testing.T) {
+	ca := newTestCA(t, "kubernetes")
+	otherCA := newTestCA(t, "other")
+
+	tests := []struct {
+		name           string
+		ca             *x509.Certificate
+		hashes         []string
+		expectWarnings int
+		expectErrors   int
+	}{
+		{
+			name:   "no artifacts",
+			hashes: []string{pubkeypin.Hash(ca)},
+		},
+		{
+			name:   "artifacts of the intended cluster",
+			ca:     ca,
+			hashes: []string{pubkeypin.Hash(ca)},
+		},
+		{
+			name:         "artifacts of another cluster",
+			ca:           otherCA,
+			hashes:       []string{pubkeypin.Hash(ca)},
+			expectErrors: 1,
+		},
+		{
+			name:           "artifacts without CA cert hashes",
+			ca:             otherCA,
+			expectWarnings: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.ca != nil {
+				if err := os.MkdirAll(filepath.Join(dir, "pki"), 0755); err != nil {
+					t.Fatalf("failed to create pki dir: %v", err)
+				}
+				if err := certutil.WriteCert(filepath.Join(dir, "pki", "ca.crt"), pem.EncodeToMemory(&pem.Block{Type: certutil.CertificateBlockType, Bytes: tt.ca.Raw})); err != nil {
+					t.Fatalf("failed to write CA: %v", err)
+				}
+			}
+
+			warnings, errs := ExistingClusterMembershipCheck{CACertHashes: tt.hashes, KubernetesDir: dir}.Check()
+			if len(warnings) != tt.expectWarnings || len(errs) != tt.expectErrors {
+				t.Errorf("expected %d warnings and %d errors, got warnings %v and errors %v", tt.expectWarnings, tt.expectErrors, warnings, errs)
+			}
+		})
+	}
+}
+
+func TestPKIPermissionsCheck(t *testing.T) {
+	tests := []struct {
+		name         string
+		files        map[string]os.FileMode
+		expectErrors int
+	}{
+		{
+			name: "private keys only readable by owner",
+			files: map[string]os.FileMode{
+				"ca.crt":        0644,
+				"ca.key":        0600,
+				"etcd/ca.key":   0400,
+				"sa.pub":        0644,
+				"apiserver.crt": 0644,
+			},
+		},
+		{
+			name: "private keys readable by group or others",
+			files: map[string]os.FileMode{
+				"ca.crt":      0644,
+				"ca.key":      0644,
+				"etcd/ca.key": 0640,
+			},
+			expectErrors: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, mode := range tt.files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("failed to create dir: %v", err)
+				}
+				if err := os.WriteFile(path, []byte("test"), mode); err != nil {
+					t.Fatalf("failed to write file: %v", err)
+				}
+				if err := os.Chmod(path, mode); err != nil {
+					t.Fatalf("failed to chmod file: %v", err)
+				}
+			}
+
+			_, errs := PKIPermissionsCheck{Dir: dir}.Check()
+			if len(errs) != tt.expectErrors {
+				t.Errorf("expected %d errors, got %v", tt.expectErrors, errs)
+			}
+		})
+	}
+
+	if warnings, errs := (PKIPermissionsCheck{Dir: filepath.Join(t.TempDir(), "missing")}).Check(); len(warnings) != 0 || len(errs) != 0 {
+		t.Errorf("expected missing dir to be skipped, got warnings %v, errors %v", warnings, errs)
+	}
+}
+
+func writeServiceAccountKeys(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	keyPEM, err := keyutil.MarshalPrivateKeyToPEM(key)
+	if err != nil {
+		t.Fatalf("failed to marshal private key: %v", err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal public key: %v", err)
+	}
+	keyPath, pubPath := filepath.Join(dir, "sa.key"), filepath.Join(dir, "sa.pub")
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	return keyPath, pubPath
+}
+
+func TestServiceAccountKeyCheck(t *testing.T) {
+	keyPath, pubPath := writeServiceAccountKeys(t, t.TempDir())
+	otherKeyPath, _ := writeServiceAccountKeys(t, t.TempDir())
+
+	tests := []struct {
+		name         string
+		keyPath      string
+		pubPath      string
+		expectErrors int
+	}{
+		{name: "matching key pair", keyPath: keyPath, pubPath: pubPath},
+		{name: "mismatched key pair", keyPath: otherKeyPath, pubPath: pubPath, expectErrors: 1},
+		{name: "missing public key", keyPath: keyPath, pubPath: filepath.Join(t.TempDir(), "sa.pub"), expectErrors: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, errs := ServiceAccountKeyCheck{KeyPath: tt.keyPath, PubPath: tt.pubPath}.Check()
+			if len(errs) != tt.expectErrors {
+				t.Errorf("expected %d errors, got %v", tt.expectErrors, errs)
+			}
+		})
+	}
+}
+
+func TestDiscoveryEndpointCheck(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	serverCA := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	otherCA := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: newTestCA(t, "other").Raw})
+
+	tests := []struct {
+		name         string
+		ca           []byte
+		expectErrors int
+	}{
+		{name: "chain validates against the CA", ca: serverCA},
+		{name: "chain signed by another CA", ca: otherCA, expectErrors: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := clientcmdapi.NewConfig()
+			config.Clusters["cluster"] = &clientcmdapi.Cluster{Server: server.URL, CertificateAuthorityData: tt.ca}
+			config.Contexts["bootstrap"] = &clientcmdapi.Context{Cluster: "cluster"}
+			config.CurrentContext = "bootstrap"
+			path := filepath.Join(t.TempDir(), "bootstrap-kubelet.conf")
+			if err := clientcmd.WriteToFile(*config, path); err != nil {
+				t.Fatalf("failed to write kubeconfig: %v", err)
+			}
+
+			_, errs := DiscoveryEndpointCheck{KubeconfigPath: path}.Check()
+			if len(errs) != tt.expectErrors {
+				t.Errorf("expected %d errors, got %v", tt.expectErrors, errs)
+			}
+		})
+	}
+}
+
+func TestHostnameServiceCollisionCheck(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "kube-dns", Namespace: metav1.NamespaceSystem}},
+		&v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "kubernetes", Namespace: metav1.NamespaceDefault}},
+		&v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "edge-1", Namespace: "apps"}},
+	)
+
+	tests := []struct {
+		name           string
+		check          HostnameServiceCollisionCheck
+		expectWarnings int
+	}{
+		{name: "no client", check: HostnameServiceCollisionCheck{NodeName: "kubernetes"}},
+		{name: "no collision", check: HostnameServiceCollisionCheck{client: client, NodeName: "edge-1"}},
+		{name: "collision", check: HostnameServiceCollisionCheck{client: client, NodeName: "kube-dns"}, expectWarnings: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, errs := tt.check.Check()
+			if len(warnings) != tt.expectWarnings || len(errs) != 0 {
+				t.Errorf("expected %d warnings and no errors, got warnings %v, errors %v", tt.expectWarnings, warnings, errs)
+			}
+		})
+	}
+}
+
+func TestNodeIPUniquenessCheck(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "edge-1"},
+			Status: v1.NodeStatus{Addresses: []v1.NodeAddress{
+				{Type: v1.NodeHostName, Address: "edge-1"},
+				{Type: v1.NodeInternalIP, Address: "192.168.1.10"},
+			}},
+		},
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "edge-2"},
+			Status: v1.NodeStatus{Addresses: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "2001:db8::10"},
+			}},
+		},
+	)
+
+	tests := []struct {
+		name           string
+		check          NodeIPUniquenessCheck
+		expectWarnings int
+		expectErrs     int
+	}{
+		{name: "no client", check: NodeIPUniquenessCheck{NodeIP: "192.168.1.10", NodeName: "edge-3"}},
+		{name: "unique ip", check: NodeIPUniquenessCheck{client: client, NodeIP: "192.168.1.11", NodeName: "edge-3"}},
+		{name: "duplicate ip", check: NodeIPUniquenessCheck{client: client, NodeIP: "192.168.1.10", NodeName: "edge-3"}, expectWarnings: 1},
+		{name: "duplicate ipv6", check: NodeIPUniquenessCheck{client: client, NodeIP: "2001:db8:0::10", NodeName: "edge-3"}, expectWarnings: 1},
+		{name: "ip of the node itself", check: NodeIPUniquenessCheck{client: client, NodeIP: "192.168.1.10", NodeName: "edge-1"}},
+		{name: "invalid ip", check: NodeIPUniquenessCheck{client: client, NodeIP: "edge-1", NodeName: "edge-3"}, expectErrs: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, errs := tt.check.Check()
+			if len(warnings) != tt.expectWarnings || len(errs) != tt.expectErrs {
+				t.Errorf("expected %d warnings and %d errors, got warnings %v, errors %v", tt.expectWarnings, tt.expectErrs, warnings, errs)
+			}
+		})
+	}
+}
+
+func TestLeftoverStateCheck(t *testing.T) {
+	dir := t.TempDir()
+	leftover := filepath.Join(dir, "bootstrap-kubelet.conf")
+	if err := os.WriteFile(leftover, []byte("test"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	if warnings, errs := (LeftoverStateCheck{Paths: []string{filepath.Join(dir, "config.yaml")}}).Check(); len(warnings) != 0 || len(errs) != 0 {
+		t.Errorf("expected no warnings and errors, got warnings %v, errors %v", warnings, errs)
+	}
+	if warnings, errs := (LeftoverStateCheck{Paths: []string{filepath.Join(dir, "config.yaml"), leftover}}).Check(); len(warnings) != 1 || len(errs) != 0 {
+		t.Errorf("expected 1 warning and no errors, got warnings %v, errors %v", warnings, errs)
+	}
+}
+
+func TestValidateCAValidityWindow(t *testing.T) {
+	now := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	newCA := func(notBefore, notAfter time.Time) *x509.Certificate {
+		return &x509.Certificate{Subject: pkix.Name{CommonName: "kubernetes"}, NotBefore: notBefore, NotAfter: notAfter}
+	}
+
+	tests := []struct {
+		name         string
+		ca           *x509.Certificate
+		expectErrors int
+	}{
+		{
+			name: "within window",
+			ca:   newCA(now.Add(-time.Hour), now.Add(time.Hour)),
+		},
+		{
+			name:         "local clock behind",
+			ca:           newCA(now.Add(time.Hour), now.Add(2*time.Hour)),
+			expectErrors: 1,
+		},
+		{
+			name:         "local clock ahead",
+			ca:           newCA(now.Add(-2*time.Hour), now.Add(-time.Hour)),
+			expectErrors: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errs := validateCAValidityWindow([]*x509.Certificate{tt.ca}, now); len(errs) != tt.expectErrors {
+				t.Errorf("expected %d errors, got %v", tt.expectErrors, errs)
+			}
+		})
+	}
+}
+
+func TestWebhookReachabilityCheck(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer l.Close()
+	reachablePort := l.Addr().(*net.TCPAddr).Port
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	unreachableURL := "https://" + closed.Addr().String() + "/validate"
+	closed.Close()
+
+	webhookService := func(name string, port int) (*v1.Service, *v1.Endpoints) {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceSystem},
+			Spec:       v1.ServiceSpec{Ports: []v1.ServicePort{{Name: "https", Port: 443}}},
+		}, &v1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceSystem},
+			Subsets: []v1.EndpointSubset{{
+				Addresses: []v1.EndpointAddress{{IP: "127.0.0.1"}},
+				Ports:     []v1.EndpointPort{{Name: "https", Port: int32(port)}},
+			}},
+		}
+	}
+	reachableSvc, reachableEps := webhookService("reachable", reachablePort)
+	noEndpointsSvc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "no-endpoints", Namespace: metav1.NamespaceSystem},
+		Spec:       v1.ServiceSpec{Ports: []v1.ServicePort{{Name: "https", Port: 443}}},
+	}
+	noEndpointsEps := &v1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "no-endpoints", Namespace: metav1.NamespaceSystem}}
+	serviceWebhook := func(name string) admissionregistrationv1.WebhookClientConfig {
+		return admissionregistrationv1.WebhookClientConfig{Service: &admissionregistrationv1.ServiceReference{Namespace: metav1.NamespaceSystem, Name: name}}
+	}
+
+	client := fake.NewSimpleClientset(
+		reachableSvc, reachableEps, noEndpointsSvc, noEndpointsEps,
+		&admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "validating"},
+			Webhooks: []admissionregistrationv1.ValidatingWebhook{
+				{Name: "reachable.openyurt.io", ClientConfig: serviceWebhook("reachable")},
+				{Name: "url.openyurt.io", ClientConfig: admissionregistrationv1.WebhookClientConfig{URL: &unreachableURL}},
+			},
+		},
+		&admissionregistrationv1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "mutating"},
+			Webhooks: []admissionregistrationv1.MutatingWebhook{
+				{Name: "no-endpoints.openyurt.io", ClientConfig: serviceWebhook("no-endpoints")},
+			},
+		},
+	)
+
+	if warnings, errs := (WebhookReachabilityCheck{}).Check(); len(warnings) != 0 || len(errs) != 0 {
+		t.Errorf("expected check to be skipped without client, got warnings %v, errors %v", warnings, errs)
+	}
+	warnings, errs := WebhookReachabilityCheck{client: client, Timeout: time.Second}.Check()
+	if len(warnings) != 2 || len(errs) != 0 {
+		t.Errorf("expected 2 warnings and no errors, got warnings %v, errors %v", warnings, errs)
+	}
+}
+
+func TestBootstrapTokenCheck(t *testing.T) {
+	created := time.Now().Add(time.Hour)
+	client := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "bootstrap-token-abcdef", Namespace: metav1.NamespaceSystem, CreationTimestamp: metav1.NewTime(created)},
+	})
+
+	tests := []struct {
+		name         string
+		check        BootstrapTokenCheck
+		expectErrors int
+	}{
+		{name: "malformed token", check: BootstrapTokenCheck{Token: "abcdef"}, expectErrors: 1},
+		{name: "no client", check: BootstrapTokenCheck{Token: "abcdef.0123456789abcdef"}},
+		{name: "secret not readable", check: BootstrapTokenCheck{client: client, Token: "ghijkl.0123456789abcdef"}},
+		{name: "clock lags", check: BootstrapTokenCheck{client: client, Token: "abcdef.0123456789abcdef"}, expectErrors: 1},
+		{name: "lag tolerated", check: BootstrapTokenCheck{client: client, Token: "abcdef.0123456789abcdef", ClockSkew: 2 * time.Hour}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, errs := tt.check.Check()
+			if len(warnings) != 0 || len(errs) != tt.expectErrors {
+				t.Errorf("expected no warnings and %d errors, got warnings %v, errors %v", tt.expectErrors, warnings, errs)
+			}
+		})
+	}
+}
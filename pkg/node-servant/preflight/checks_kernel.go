@@ -0,0 +1,783 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/klog/v2"
+)
+
+const (
+	procCmdlinePath = "/proc/cmdline"
+
+	maxUserNamespacesSysctl       = "user.max_user_namespaces"
+	unprivilegedUsernsCloneSysctl = "kernel.unprivileged_userns_clone"
+
+	sysctlConfFile = "/etc/sysctl.conf"
+	sysctlConfDir  = "/etc/sysctl.d"
+
+	currentClocksourcePath = "/sys/devices/system/clocksource/clocksource0/current_clocksource"
+
+	hugePagesDir = "/sys/kernel/mm/hugepages"
+)
+
+// defaultCgroupV1Controllers are the cgroup v1 controllers kubelet requires.
+var defaultCgroupV1Controllers = []string{"cpu", "cpuacct", "cpuset", "memory", "pids", "hugetlb"}
+
+// unreliableClocksources are the clock sources known to jump or drift in virtualized environments.
+var unreliableClocksources = map[string]bool{
+	"jiffies":         true,
+	"refined-jiffies": true,
+}
+
+// KernelCmdlineCheck verifies the boot parameters of the running kernel.
+// A token without '=' (e.g. "cgroup_no_v1") matches the bare parameter as well as
+// any value of it, while a token with '=' (e.g. "ipv6.disable=1") must match exactly.
+type KernelCmdlineCheck struct {
+	Required  []string
+	Forbidden []string
+}
+
+func (KernelCmdlineCheck) Name() string {
+	return "KernelCmdline"
+}
+
+func (kc KernelCmdlineCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"required": kc.Required, "forbidden": kc.Forbidden}
+}
+
+func (kc KernelCmdlineCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infoln("validating kernel command line parameters")
+
+	content, err := os.ReadFile(procCmdlinePath)
+	if err != nil {
+		return []error{errors.Wrapf(err, "unable to read %s", procCmdlinePath)}, nil
+	}
+	return checkKernelCmdline(string(content), kc.Required, kc.Forbidden), nil
+}
+
+// checkKernelCmdline returns a warning for every required token absent from cmdline
+// and every forbidden token present in it.
+func checkKernelCmdline(cmdline string, required, forbidden []string) []error {
+	var warnings []error
+	params := strings.Fields(cmdline)
+	for _, token := range required {
+		if !hasKernelParam(params, token) {
+			warnings = append(warnings, errors.Errorf("required kernel parameter %q is not set in %s", token, procCmdlinePath))
+		}
+	}
+	for _, token := range forbidden {
+		if hasKernelParam(params, token) {
+			warnings = append(warnings, errors.Errorf("kernel parameter %q is set in %s, which is not supported", token, procCmdlinePath))
+		}
+	}
+	return warnings
+}
+
+func hasKernelParam(params []string, token string) bool {
+	for _, param := range params {
+		if param == token {
+			return true
+		}
+		if !strings.Contains(token, "=") && strings.HasPrefix(param, token+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// UserNamespaceCheck verifies that user namespaces are enabled for rootless container scenarios.
+type UserNamespaceCheck struct{}
+
+func (UserNamespaceCheck) Name() string {
+	return "UserNamespace"
+}
+
+func (UserNamespaceCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infoln("validating user namespaces")
+
+	max, err := readSysctlInt(maxUserNamespacesSysctl)
+	if err != nil {
+		return []error{errors.Wrapf(err, "unable to read %s", maxUserNamespacesSysctl)}, nil
+	}
+	values := map[string]int{maxUserNamespacesSysctl: max}
+
+	// kernel.unprivileged_userns_clone only exists on some distributions (e.g. Debian)
+	clone, err := readSysctlInt(unprivilegedUsernsCloneSysctl)
+	if err == nil {
+		values[unprivilegedUsernsCloneSysctl] = clone
+	} else if !os.IsNotExist(err) {
+		warnings = append(warnings, errors.Wrapf(err, "unable to read %s", unprivilegedUsernsCloneSysctl))
+	}
+	return append(warnings, evaluateUserNamespaces(values)...), nil
+}
+
+// evaluateUserNamespaces returns a warning for every user namespace sysctl in values that disables them.
+func evaluateUserNamespaces(values map[string]int) (warnings []error) {
+	if max, ok := values[maxUserNamespacesSysctl]; ok && max == 0 {
+		warnings = append(warnings, errors.Errorf("user namespaces are disabled, %s is 0", maxUserNamespacesSysctl))
+	}
+	if clone, ok := values[unprivilegedUsernsCloneSysctl]; ok && clone == 0 {
+		warnings = append(warnings, errors.Errorf("unprivileged user namespaces are disabled, %s is 0", unprivilegedUsernsCloneSysctl))
+	}
+	return warnings
+}
+
+// SysctlCheck verifies the runtime values of sysctls. With RequirePersistence, it also warns
+// when a sysctl has the expected value at runtime but isn't persisted in /etc/sysctl.conf
+// or /etc/sysctl.d/*.conf, so it would revert on reboot.
+type SysctlCheck struct {
+	// Sysctls maps sysctl names (e.g. net.ipv4.ip_forward) to their expected values.
+	Sysctls            map[string]string
+	RequirePersistence bool
+}
+
+func (SysctlCheck) Name() string {
+	return "Sysctl"
+}
+
+func (sc SysctlCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"sysctls":            sc.Sysctls,
+		"requirePersistence": sc.RequirePersistence,
+	}
+}
+
+func (sc SysctlCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infof("validating sysctls %v", sc.Sysctls)
+
+	names := make([]string, 0, len(sc.Sysctls))
+	for name := range sc.Sysctls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var persisted map[string]string
+	if sc.RequirePersistence {
+		persisted = loadPersistedSysctls()
+	}
+
+	for _, name := range names {
+		expected := sc.Sysctls[name]
+		value, err := readSysctl(name)
+		if err != nil {
+			errorList = append(errorList, errors.Wrapf(err, "unable to read sysctl %s", name))
+			continue
+		}
+		if value != expected {
+			errorList = append(errorList, errors.Errorf("sysctl %s is set to %s instead of %s", name, value, expected))
+			continue
+		}
+		if sc.RequirePersistence && persisted[name] != expected {
+			warnings = append(warnings, errors.Errorf("sysctl %s=%s is not persisted in %s or %s/*.conf, it will be reverted on reboot", name, expected, sysctlConfFile, sysctlConfDir))
+		}
+	}
+	return warnings, errorList
+}
+
+// defaultNetworkTuningMinimums are the recommended minimums of the connection backlog sysctls
+// for nodes handling many connections, e.g. control-plane nodes running the API server or ingress.
+var defaultNetworkTuningMinimums = map[string]int{
+	"net.core.somaxconn":           32768,
+	"net.ipv4.tcp_max_syn_backlog": 8192,
+	"net.core.netdev_max_backlog":  16384,
+}
+
+// NetworkTuningCheck warns when the connection backlog sysctls are below the recommended minimums,
+// the kernel defaults can cause connection drops under load.
+type NetworkTuningCheck struct {
+	// Minimums maps sysctl names to their minimum values, defaults to the recommended minimums of
+	// net.core.somaxconn, net.ipv4.tcp_max_syn_backlog and net.core.netdev_max_backlog.
+	Minimums map[string]int
+}
+
+func (NetworkTuningCheck) Name() string {
+	return "NetworkTuning"
+}
+
+func (ntc NetworkTuningCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"minimums": ntc.Minimums}
+}
+
+func (ntc NetworkTuningCheck) Check() (warnings, errorList []error) {
+	minimums := ntc.Minimums
+	if len(minimums) == 0 {
+		minimums = defaultNetworkTuningMinimums
+	}
+	klog.V(1).Infof("validating network tuning sysctls %v", minimums)
+
+	names := make([]string, 0, len(minimums))
+	for name := range minimums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := map[string]int{}
+	for _, name := range names {
+		value, err := readSysctlInt(name)
+		if err != nil {
+			warnings = append(warnings, errors.Wrapf(err, "unable to read sysctl %s", name))
+			continue
+		}
+		values[name] = value
+	}
+	return append(warnings, evaluateSysctlMinimums(minimums, values)...), nil
+}
+
+// evaluateSysctlMinimums returns a warning for every sysctl in values which is less than its minimum.
+func evaluateSysctlMinimums(minimums, values map[string]int) (warnings []error) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := validateSysctlMinimum(name, values[name], minimums[name]); err != nil {
+			warnings = append(warnings, err)
+		}
+	}
+	return warnings
+}
+
+// loadPersistedSysctls returns the sysctls set in /etc/sysctl.d/*.conf and /etc/sysctl.conf,
+// the latter takes precedence as it is applied last by systemd-sysctl.
+func loadPersistedSysctls() map[string]string {
+	persisted := map[string]string{}
+	files, _ := filepath.Glob(filepath.Join(sysctlConfDir, "*.conf"))
+	sort.Strings(files)
+	files = append(files, sysctlConfFile)
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		for name, value := range parseSysctlConf(string(content)) {
+			persisted[name] = value
+		}
+	}
+	return persisted
+}
+
+// parseSysctlConf parses the content of a sysctl.conf file, names using '/' as
+// separator are normalized to use '.'.
+func parseSysctlConf(content string) map[string]string {
+	sysctls := map[string]string{}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		// a leading '-' means errors setting the sysctl are ignored
+		name := strings.TrimPrefix(strings.TrimSpace(parts[0]), "-")
+		sysctls[strings.Replace(name, "/", ".", -1)] = strings.TrimSpace(parts[1])
+	}
+	return sysctls
+}
+
+// ClockSourceCheck verifies that the kernel clock source is not a known unreliable one, which
+// happens in some virtualized environments and makes timers jump.
+type ClockSourceCheck struct{}
+
+func (ClockSourceCheck) Name() string {
+	return "ClockSource"
+}
+
+func (ClockSourceCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infoln("validating kernel clock source")
+
+	content, err := os.ReadFile(currentClocksourcePath)
+	if err != nil {
+		return []error{errors.Wrapf(err, "unable to read %s", currentClocksourcePath)}, nil
+	}
+	if err := validateClocksource(string(content)); err != nil {
+		return []error{err}, nil
+	}
+	return nil, nil
+}
+
+// validateClocksource returns an error if the clock source in content of current_clocksource is unreliable.
+func validateClocksource(content string) error {
+	clocksource := strings.TrimSpace(content)
+	if unreliableClocksources[clocksource] {
+		return errors.Errorf("kernel clock source is %s, which is unreliable, a stable clock source such as tsc or kvm-clock is recommended", clocksource)
+	}
+	return nil
+}
+
+// RPFilterCheck verifies the reverse path filtering mode, strict mode (1) breaks the asymmetric
+// routing used by some CNI setups, which commonly need loose mode (2).
+type RPFilterCheck struct {
+	Expected int
+}
+
+func (RPFilterCheck) Name() string {
+	return "RPFilter"
+}
+
+func (rfc RPFilterCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"expected": rfc.Expected}
+}
+
+func (rfc RPFilterCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infof("validating rp_filter is %d", rfc.Expected)
+
+	values := map[string]int{}
+	for _, name := range rpFilterSysctls {
+		value, err := readSysctlInt(name)
+		if err != nil {
+			warnings = append(warnings, errors.Wrapf(err, "unable to read sysctl %s", name))
+			continue
+		}
+		values[name] = value
+	}
+	return append(warnings, evaluateRPFilter(rfc.Expected, values)...), nil
+}
+
+// rpFilterSysctls are the sysctls validated by RPFilterCheck.
+var rpFilterSysctls = []string{"net.ipv4.conf.all.rp_filter", "net.ipv4.conf.default.rp_filter"}
+
+// evaluateRPFilter returns a warning for every rp_filter sysctl in values which isn't expected.
+func evaluateRPFilter(expected int, values map[string]int) (warnings []error) {
+	for _, name := range rpFilterSysctls {
+		if value, ok := values[name]; ok && value != expected {
+			warnings = append(warnings, errors.Errorf("sysctl %s is %d instead of %d", name, value, expected))
+		}
+	}
+	return warnings
+}
+
+// HugePagesCheck verifies that enough huge pages are allocated for every required page size.
+type HugePagesCheck struct {
+	// MinPages maps page sizes (e.g. 2Mi, 1Gi) to the minimum number of allocated pages.
+	MinPages map[string]int
+}
+
+func (HugePagesCheck) Name() string {
+	return "HugePages"
+}
+
+func (hpc HugePagesCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"minPages": hpc.MinPages}
+}
+
+func (hpc HugePagesCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infof("validating huge pages %v", hpc.MinPages)
+	return checkHugePages(hugePagesDir, hpc.MinPages), nil
+}
+
+// checkHugePages returns a warning for every page size in minPages with less pages allocated under dir.
+func checkHugePages(dir string, minPages map[string]int) (warnings []error) {
+	sizes := make([]string, 0, len(minPages))
+	for size := range minPages {
+		sizes = append(sizes, size)
+	}
+	sort.Strings(sizes)
+
+	for _, size := range sizes {
+		min := minPages[size]
+		quantity, err := resource.ParseQuantity(size)
+		if err != nil {
+			warnings = append(warnings, errors.Wrapf(err, "invalid huge page size %q", size))
+			continue
+		}
+		path := filepath.Join(dir, fmt.Sprintf("hugepages-%dkB", quantity.Value()/1024), "nr_hugepages")
+		content, err := os.ReadFile(path)
+		if err != nil {
+			warnings = append(warnings, errors.Wrapf(err, "huge page size %s is not supported by the kernel", size))
+			continue
+		}
+		pages, err := strconv.Atoi(strings.TrimSpace(string(content)))
+		if err != nil {
+			warnings = append(warnings, errors.Wrapf(err, "unable to parse %s", path))
+			continue
+		}
+		if pages < min {
+			warnings = append(warnings, errors.Errorf("%d huge pages of size %s are allocated, less than the required %d", pages, size, min))
+		}
+	}
+	return warnings
+}
+
+// CgroupV1ControllersCheck verifies that the required cgroup v1 controllers are mounted under
+// /sys/fs/cgroup. It's a no-op when the unified cgroup v2 hierarchy is in use.
+type CgroupV1ControllersCheck struct {
+	// Required defaults to the controllers kubelet requires.
+	Required []string
+}
+
+func (CgroupV1ControllersCheck) Name() string {
+	return "CgroupV1Controllers"
+}
+
+func (cvc CgroupV1ControllersCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"required": cvc.Required}
+}
+
+func (cvc CgroupV1ControllersCheck) Check() (warnings, errorList []error) {
+	if isCgroupV2Unified() {
+		klog.V(1).Infoln("cgroup v2 unified hierarchy is in use, skipping cgroup v1 controllers check")
+		return nil, nil
+	}
+
+	required := cvc.Required
+	if len(required) == 0 {
+		required = defaultCgroupV1Controllers
+	}
+	klog.V(1).Infof("validating cgroup v1 controllers %v", required)
+
+	mounts, err := readMounts()
+	if err != nil {
+		return nil, []error{errors.Wrapf(err, "unable to read %s", procMountsPath)}
+	}
+	if missing := missingCgroupV1Controllers(mounts, cgroupRootDir, required); len(missing) != 0 {
+		return nil, []error{errors.Errorf("cgroup v1 controllers %v are not mounted under %s", missing, cgroupRootDir)}
+	}
+	return nil, nil
+}
+
+// missingCgroupV1Controllers returns the required controllers which aren't mounted under root,
+// or whose directory under root is missing.
+func missingCgroupV1Controllers(mounts []mountEntry, root string, required []string) []string {
+	mounted := map[string]bool{}
+	for _, m := range mounts {
+		if m.FSType != "cgroup" || !isSubPath(m.MountPoint, root) {
+			continue
+		}
+		for _, opt := range m.Options {
+			mounted[opt] = true
+		}
+	}
+
+	var missing []string
+	for _, controller := range required {
+		if !mounted[controller] {
+			missing = append(missing, controller)
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(root, controller)); err != nil {
+			missing = append(missing, controller)
+		}
+	}
+	return missing
+}
+
+// SystemFileMaxCheck verifies that the system-wide limit of open files fs.file-max is at least Min,
+// beyond the per-process RLIMIT_NOFILE it may bottleneck a node running many pods.
+type SystemFileMaxCheck struct {
+	Min int
+}
+
+func (SystemFileMaxCheck) Name() string {
+	return "SystemFileMax"
+}
+
+func (sfc SystemFileMaxCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"min": sfc.Min}
+}
+
+func (sfc SystemFileMaxCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infof("validating fs.file-max is at least %d", sfc.Min)
+
+	value, err := readSysctlInt("fs.file-max")
+	if err != nil {
+		return []error{errors.Wrap(err, "unable to read sysctl fs.file-max")}, nil
+	}
+	if err := validateSysctlMinimum("fs.file-max", value, sfc.Min); err != nil {
+		return []error{err}, nil
+	}
+	return nil, nil
+}
+
+// validateSysctlMinimum returns an error if the value of the sysctl is less than the recommended min.
+func validateSysctlMinimum(name string, value, min int) error {
+	if value < min {
+		return errors.Errorf("sysctl %s is %d, which is less than the recommended %d", name, value, min)
+	}
+	return nil
+}
+
+// ThreadsMaxCheck warns when the system-wide limit of threads kernel.threads-max is below Min,
+// kubelet and the workloads spawn many threads and fail to fork under load otherwise.
+type ThreadsMaxCheck struct {
+	Min int
+}
+
+func (ThreadsMaxCheck) Name() string {
+	return "ThreadsMax"
+}
+
+func (tmc ThreadsMaxCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"min": tmc.Min}
+}
+
+func (tmc ThreadsMaxCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infof("validating kernel.threads-max is at least %d", tmc.Min)
+
+	value, err := readSysctlInt("kernel.threads-max")
+	if err != nil {
+		return []error{errors.Wrap(err, "unable to read sysctl kernel.threads-max")}, nil
+	}
+	if err := validateSysctlMinimum("kernel.threads-max", value, tmc.Min); err != nil {
+		return []error{err}, nil
+	}
+	return nil, nil
+}
+
+const (
+	inotifyMaxUserWatchesSysctl   = "fs.inotify.max_user_watches"
+	inotifyMaxUserInstancesSysctl = "fs.inotify.max_user_instances"
+	defaultInotifyMinWatches      = 524288
+	defaultInotifyMinInstances    = 512
+)
+
+// InotifyLimitsCheck warns when the inotify limits are below the minimums, kubelet and the
+// fsnotify-heavy components of a dense node run out of watches or instances otherwise.
+type InotifyLimitsCheck struct {
+	// MinWatches defaults to 524288.
+	MinWatches int
+	// MinInstances defaults to 512.
+	MinInstances int
+}
+
+func (InotifyLimitsCheck) Name() string {
+	return "InotifyLimits"
+}
+
+func (ilc InotifyLimitsCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"minWatches":   ilc.MinWatches,
+		"minInstances": ilc.MinInstances,
+	}
+}
+
+func (ilc InotifyLimitsCheck) Check() (warnings, errorList []error) {
+	minimums := inotifyMinimums(ilc.MinWatches, ilc.MinInstances)
+	klog.V(1).Infof("validating inotify limits are at least %d watches and %d instances", minimums[inotifyMaxUserWatchesSysctl], minimums[inotifyMaxUserInstancesSysctl])
+
+	values := map[string]int{}
+	for _, name := range []string{inotifyMaxUserWatchesSysctl, inotifyMaxUserInstancesSysctl} {
+		value, err := readSysctlInt(name)
+		if err != nil {
+			warnings = append(warnings, errors.Wrapf(err, "unable to read sysctl %s", name))
+			continue
+		}
+		values[name] = value
+	}
+	return append(warnings, evaluateSysctlMinimums(minimums, values)...), nil
+}
+
+// inotifyMinimums returns the minimums of the inotify sysctls, zero values are replaced by the defaults.
+func inotifyMinimums(minWatches, minInstances int) map[string]int {
+	if minWatches == 0 {
+		minWatches = defaultInotifyMinWatches
+	}
+	if minInstances == 0 {
+		minInstances = defaultInotifyMinInstances
+	}
+	return map[string]int{
+		inotifyMaxUserWatchesSysctl:   minWatches,
+		inotifyMaxUserInstancesSysctl: minInstances,
+	}
+}
+
+const (
+	seccompSysctlDir = "/proc/sys/kernel/seccomp"
+	bootConfigPrefix = "/boot/config-"
+)
+
+// seccompKernelConfigs are the kernel configs the default seccomp profile requires.
+var seccompKernelConfigs = []string{"CONFIG_SECCOMP", "CONFIG_SECCOMP_FILTER"}
+
+// capacityBudget is a system limit bounding the pod density, with a rough usage of the
+// system itself and of every pod.
+type capacityBudget struct {
+	sysctl string
+	base   int
+	perPod int
+}
+
+// capacityBudgets are the budgets cross-checked by CapacityBudgetCheck.
+var capacityBudgets = []capacityBudget{
+	{sysctl: "kernel.pid_max", base: 4096, perPod: 256},
+	{sysctl: "fs.file-max", base: 65536, perPod: 1024},
+	{sysctl: "net.netfilter.nf_conntrack_max", base: 32768, perPod: 1024},
+}
+
+// CapacityBudgetCheck warns when pid_max, file-max or nf_conntrack_max is likely insufficient for
+// MaxPods pods, using rough per-pod heuristics, and reports the tightest one as the bottleneck.
+// Budgets that can't be read (e.g. conntrack module not loaded) are skipped.
+type CapacityBudgetCheck struct {
+	// MaxPods defaults to 110.
+	MaxPods int
+}
+
+func (CapacityBudgetCheck) Name() string {
+	return "CapacityBudget"
+}
+
+func (cbc CapacityBudgetCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"maxPods": cbc.MaxPods}
+}
+
+func (cbc CapacityBudgetCheck) Check() (warnings, errorList []error) {
+	maxPods := cbc.MaxPods
+	if maxPods == 0 {
+		maxPods = defaultMaxPods
+	}
+	klog.V(1).Infof("validating system limits fit %d pods", maxPods)
+
+	values := map[string]int{}
+	for _, budget := range capacityBudgets {
+		value, err := readSysctlInt(budget.sysctl)
+		if err != nil {
+			klog.V(1).Infof("unable to read sysctl %s, skipping: %v", budget.sysctl, err)
+			continue
+		}
+		values[budget.sysctl] = value
+	}
+	return evaluateCapacityBudgets(maxPods, values), nil
+}
+
+// evaluateCapacityBudgets returns a warning for every budget in values insufficient for maxPods,
+// the bottleneck, i.e. the budget covering the smallest share of its need, is reported last.
+func evaluateCapacityBudgets(maxPods int, values map[string]int) (warnings []error) {
+	bottleneck, lowestShare := "", 0.0
+	for _, budget := range capacityBudgets {
+		value, ok := values[budget.sysctl]
+		if !ok {
+			continue
+		}
+		needed := budget.base + budget.perPod*maxPods
+		if value >= needed {
+			continue
+		}
+		warnings = append(warnings, errors.Errorf("sysctl %s is %d, which is likely insufficient for %d pods (about %d needed)", budget.sysctl, value, maxPods, needed))
+		if share := float64(value) / float64(needed); bottleneck == "" || share < lowestShare {
+			bottleneck, lowestShare = budget.sysctl, share
+		}
+	}
+	if bottleneck != "" {
+		warnings = append(warnings, errors.Errorf("%s is the bottleneck for %d pods, please raise it or lower maxPods", bottleneck, maxPods))
+	}
+	return warnings
+}
+
+// SeccompSupportCheck warns when the kernel doesn't support seccomp filters, which the
+// RuntimeDefault seccomp profile of kubelet and some workloads require. The presence of
+// /proc/sys/kernel/seccomp is relied on first, and the kernel config under /boot otherwise.
+type SeccompSupportCheck struct{}
+
+func (SeccompSupportCheck) Name() string {
+	return "SeccompSupport"
+}
+
+func (SeccompSupportCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infoln("validating seccomp support of the kernel")
+
+	if _, err := os.Stat(seccompSysctlDir); err == nil {
+		return nil, nil
+	}
+	release, err := readSysctl("kernel.osrelease")
+	if err != nil {
+		return []error{errors.Wrap(err, "unable to read kernel release")}, nil
+	}
+	path := bootConfigPrefix + release
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return []error{errors.Wrapf(err, "%s doesn't exist and unable to read kernel config, seccomp support is unknown", seccompSysctlDir)}, nil
+	}
+	if missing := missingKernelConfigs(string(content), seccompKernelConfigs); len(missing) != 0 {
+		return []error{errors.Errorf("kernel configs %v are not enabled in %s, seccomp profiles will not work", missing, path)}, nil
+	}
+	return nil, nil
+}
+
+// missingKernelConfigs returns the configs in required that are neither built in (=y) nor modules (=m) in the kernel config content.
+func missingKernelConfigs(content string, required []string) []string {
+	enabled := map[string]bool{}
+	for _, line := range strings.Split(content, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(parts) == 2 && (parts[1] == "y" || parts[1] == "m") {
+			enabled[parts[0]] = true
+		}
+	}
+	var missing []string
+	for _, config := range required {
+		if !enabled[config] {
+			missing = append(missing, config)
+		}
+	}
+	return missing
+}
+
+// CgroupHierarchyExpectationCheck verifies that the active cgroup hierarchy is the version kubelet
+// is configured for, e.g. kubelet expects cgroup v2 but the system booted with cgroup v1.
+type CgroupHierarchyExpectationCheck struct {
+	// ExpectedVersion is 1 or 2.
+	ExpectedVersion int
+}
+
+func (CgroupHierarchyExpectationCheck) Name() string {
+	return "CgroupHierarchyExpectation"
+}
+
+func (chc CgroupHierarchyExpectationCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"expectedVersion": chc.ExpectedVersion}
+}
+
+func (chc CgroupHierarchyExpectationCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infof("validating cgroup v%d hierarchy is active", chc.ExpectedVersion)
+
+	active := 1
+	if isCgroupV2Unified() {
+		active = 2
+	}
+	if err := validateCgroupHierarchy(chc.ExpectedVersion, active); err != nil {
+		return nil, []error{err}
+	}
+	return nil, nil
+}
+
+// validateCgroupHierarchy returns an error with the kernel cmdline remediation if active is not expected.
+func validateCgroupHierarchy(expected, active int) error {
+	var param string
+	switch expected {
+	case 1:
+		param = "systemd.unified_cgroup_hierarchy=0"
+	case 2:
+		param = "systemd.unified_cgroup_hierarchy=1"
+	default:
+		return errors.Errorf("unsupported expected cgroup version %d, it should be 1 or 2", expected)
+	}
+	if active != expected {
+		return errors.Errorf("kubelet expects cgroup v%d but cgroup v%d is active, please add %s to the kernel cmdline (e.g. GRUB_CMDLINE_LINUX in /etc/default/grub), regenerate the grub config and reboot", expected, active, param)
+	}
+	return nil
+}
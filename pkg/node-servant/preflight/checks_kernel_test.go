@@ -0,0 +1,423 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckKernelCmdline(t *testing.T) {
+	cmdline := "BOOT_IMAGE=/vmlinuz root=/dev/sda1 ro systemd.unified_cgroup_hierarchy=1 ipv6.disable=1 quiet\n"
+	tests := []struct {
+		name      string
+		required  []string
+		forbidden []string
+		expected  int
+	}{
+		{
+			name:     "required parameter with value present",
+			required: []string{"systemd.unified_cgroup_hierarchy=1"},
+			expected: 0,
+		},
+		{
+			name:     "required bare parameter matches any value",
+			required: []string{"systemd.unified_cgroup_hierarchy", "quiet"},
+			expected: 0,
+		},
+		{
+			name:     "required parameter with different value",
+			required: []string{"systemd.unified_cgroup_hierarchy=0"},
+			expected: 1,
+		},
+		{
+			name:     "required parameter absent",
+			required: []string{"cgroup_no_v1"},
+			expected: 1,
+		},
+		{
+			name:      "forbidden parameter present",
+			forbidden: []string{"ipv6.disable=1"},
+			expected:  1,
+		},
+		{
+			name:      "forbidden parameter absent",
+			forbidden: []string{"ipv6.disable=0", "nosmt"},
+			expected:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings := checkKernelCmdline(cmdline, tt.required, tt.forbidden)
+			if len(warnings) != tt.expected {
+				t.Errorf("expected %d warnings, got %d: %v", tt.expected, len(warnings), warnings)
+			}
+		})
+	}
+}
+
+func TestEvaluateUserNamespaces(t *testing.T) {
+	tests := []struct {
+		name           string
+		values         map[string]int
+		expectWarnings int
+	}{
+		{
+			name:   "enabled",
+			values: map[string]int{maxUserNamespacesSysctl: 63457, unprivilegedUsernsCloneSysctl: 1},
+		},
+		{
+			name:   "enabled without unprivileged_userns_clone",
+			values: map[string]int{maxUserNamespacesSysctl: 63457},
+		},
+		{
+			name:           "disabled",
+			values:         map[string]int{maxUserNamespacesSysctl: 0},
+			expectWarnings: 1,
+		},
+		{
+			name:           "unprivileged disabled",
+			values:         map[string]int{maxUserNamespacesSysctl: 63457, unprivilegedUsernsCloneSysctl: 0},
+			expectWarnings: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if warnings := evaluateUserNamespaces(tt.values); len(warnings) != tt.expectWarnings {
+				t.Errorf("expected %d warnings, got %v", tt.expectWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestParseSysctlConf(t *testing.T) {
+	content := `# Kubernetes settings
+net.ipv4.ip_forward = 1
+; comment
+net/bridge/bridge-nf-call-iptables=1
+-net.ipv6.conf.all.forwarding = 1
+invalid line
+`
+	expected := map[string]string{
+		"net.ipv4.ip_forward":                "1",
+		"net.bridge.bridge-nf-call-iptables": "1",
+		"net.ipv6.conf.all.forwarding":       "1",
+	}
+	if sysctls := parseSysctlConf(content); !reflect.DeepEqual(sysctls, expected) {
+		t.Errorf("expected sysctls %v, got %v", expected, sysctls)
+	}
+}
+
+func TestEvaluateSysctlMinimums(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   map[string]int
+		expected []string
+	}{
+		{
+			name:   "tuned",
+			values: map[string]int{"net.core.somaxconn": 65535, "net.ipv4.tcp_max_syn_backlog": 8192, "net.core.netdev_max_backlog": 16384},
+		},
+		{
+			name:     "kernel defaults",
+			values:   map[string]int{"net.core.somaxconn": 4096, "net.ipv4.tcp_max_syn_backlog": 1024, "net.core.netdev_max_backlog": 1000},
+			expected: []string{"net.core.netdev_max_backlog", "net.core.somaxconn", "net.ipv4.tcp_max_syn_backlog"},
+		},
+		{
+			name:     "unreadable sysctls are skipped",
+			values:   map[string]int{"net.core.somaxconn": 4096},
+			expected: []string{"net.core.somaxconn"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings := evaluateSysctlMinimums(defaultNetworkTuningMinimums, tt.values)
+			if len(warnings) != len(tt.expected) {
+				t.Fatalf("expected warnings for %v, got %v", tt.expected, warnings)
+			}
+			for i, name := range tt.expected {
+				if !strings.HasPrefix(warnings[i].Error(), "sysctl "+name+" is") {
+					t.Errorf("expected warning for %s, got %v", name, warnings[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateClocksource(t *testing.T) {
+	tests := []struct {
+		content   string
+		expectErr bool
+	}{
+		{content: "tsc\n"},
+		{content: "kvm-clock\n"},
+		{content: "jiffies\n", expectErr: true},
+		{content: "refined-jiffies\n", expectErr: true},
+	}
+	for _, tt := range tests {
+		if err := validateClocksource(tt.content); (err != nil) != tt.expectErr {
+			t.Errorf("%q: expected error %v, got %v", tt.content, tt.expectErr, err)
+		}
+	}
+}
+
+func TestEvaluateRPFilter(t *testing.T) {
+	tests := []struct {
+		name           string
+		expected       int
+		values         map[string]int
+		expectWarnings int
+	}{
+		{
+			name:     "loose mode",
+			expected: 2,
+			values:   map[string]int{"net.ipv4.conf.all.rp_filter": 2, "net.ipv4.conf.default.rp_filter": 2},
+		},
+		{
+			name:           "strict mode",
+			expected:       2,
+			values:         map[string]int{"net.ipv4.conf.all.rp_filter": 1, "net.ipv4.conf.default.rp_filter": 1},
+			expectWarnings: 2,
+		},
+		{
+			name:           "default unreadable",
+			expected:       0,
+			values:         map[string]int{"net.ipv4.conf.all.rp_filter": 1},
+			expectWarnings: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if warnings := evaluateRPFilter(tt.expected, tt.values); len(warnings) != tt.expectWarnings {
+				t.Errorf("expected %d warnings, got %v", tt.expectWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestCheckHugePages(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "hugepages-2048kB"), 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "hugepages-2048kB", "nr_hugepages"), []byte("512\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		minPages       map[string]int
+		expectWarnings int
+	}{
+		{name: "enough pages", minPages: map[string]int{"2Mi": 512}},
+		{name: "not enough pages", minPages: map[string]int{"2Mi": 1024}, expectWarnings: 1},
+		{name: "unsupported page size", minPages: map[string]int{"2Mi": 256, "1Gi": 1}, expectWarnings: 1},
+		{name: "invalid page size", minPages: map[string]int{"huge": 1}, expectWarnings: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if warnings := checkHugePages(dir, tt.minPages); len(warnings) != tt.expectWarnings {
+				t.Errorf("expected %d warnings, got %v", tt.expectWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestMissingCgroupV1Controllers(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"cpu", "memory", "pids"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", dir, err)
+		}
+	}
+	if err := os.Symlink("cpu", filepath.Join(root, "cpuacct")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	cgroup := func(mountPoint string, opts ...string) mountEntry {
+		return mountEntry{Device: "cgroup", MountPoint: mountPoint, FSType: "cgroup", Options: append([]string{"rw"}, opts...)}
+	}
+	mounts := []mountEntry{
+		{Device: "tmpfs", MountPoint: root, FSType: "tmpfs", Options: []string{"ro"}},
+		cgroup(filepath.Join(root, "cpu"), "cpu", "cpuacct"),
+		cgroup(filepath.Join(root, "memory"), "memory"),
+		cgroup(filepath.Join(root, "devices"), "devices"),
+		cgroup("/mnt/pids", "pids"),
+	}
+
+	tests := []struct {
+		name     string
+		required []string
+		expected []string
+	}{
+		{name: "all mounted", required: []string{"cpu", "cpuacct", "memory"}},
+		{name: "mounted outside root", required: []string{"memory", "pids"}, expected: []string{"pids"}},
+		{name: "directory missing", required: []string{"devices"}, expected: []string{"devices"}},
+		{name: "not mounted", required: []string{"cpu", "hugetlb"}, expected: []string{"hugetlb"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if missing := missingCgroupV1Controllers(mounts, root, tt.required); !reflect.DeepEqual(missing, tt.expected) {
+				t.Errorf("expected missing controllers %v, got %v", tt.expected, missing)
+			}
+		})
+	}
+}
+
+func TestValidateSysctlMinimum(t *testing.T) {
+	tests := []struct {
+		name      string
+		sysctl    string
+		value     int
+		min       int
+		expectErr bool
+	}{
+		{name: "file-max above minimum", sysctl: "fs.file-max", value: 9223372036854775807, min: 1048576},
+		{name: "file-max at minimum", sysctl: "fs.file-max", value: 1048576, min: 1048576},
+		{name: "file-max below minimum", sysctl: "fs.file-max", value: 65536, min: 1048576, expectErr: true},
+		{name: "no minimum", sysctl: "fs.file-max", value: 0, min: 0},
+		{name: "threads-max above minimum", sysctl: "kernel.threads-max", value: 254508, min: 100000},
+		{name: "threads-max one below minimum", sysctl: "kernel.threads-max", value: 99999, min: 100000, expectErr: true},
+		{name: "threads-max of a small container", sysctl: "kernel.threads-max", value: 7767, min: 100000, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSysctlMinimum(tt.sysctl, tt.value, tt.min)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("expected error %v, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestInotifyMinimums(t *testing.T) {
+	tests := []struct {
+		name                     string
+		minWatches, minInstances int
+		values                   map[string]int
+		expectWarnings           int
+	}{
+		{
+			name:   "defaults met",
+			values: map[string]int{inotifyMaxUserWatchesSysctl: 524288, inotifyMaxUserInstancesSysctl: 8192},
+		},
+		{
+			name:           "distribution defaults",
+			values:         map[string]int{inotifyMaxUserWatchesSysctl: 8192, inotifyMaxUserInstancesSysctl: 128},
+			expectWarnings: 2,
+		},
+		{
+			name:         "custom minimums",
+			minWatches:   8192,
+			minInstances: 128,
+			values:       map[string]int{inotifyMaxUserWatchesSysctl: 8192, inotifyMaxUserInstancesSysctl: 128},
+		},
+		{
+			name:           "custom watches only",
+			minWatches:     1048576,
+			values:         map[string]int{inotifyMaxUserWatchesSysctl: 524288, inotifyMaxUserInstancesSysctl: 512},
+			expectWarnings: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			minimums := inotifyMinimums(tt.minWatches, tt.minInstances)
+			if warnings := evaluateSysctlMinimums(minimums, tt.values); len(warnings) != tt.expectWarnings {
+				t.Errorf("expected %d warnings, got %v", tt.expectWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestValidateCgroupHierarchy(t *testing.T) {
+	tests := []struct {
+		expected  int
+		active    int
+		expectErr bool
+	}{
+		{expected: 1, active: 1},
+		{expected: 2, active: 2},
+		{expected: 2, active: 1, expectErr: true},
+		{expected: 1, active: 2, expectErr: true},
+		{expected: 3, active: 2, expectErr: true},
+	}
+	for _, tt := range tests {
+		if err := validateCgroupHierarchy(tt.expected, tt.active); (err != nil) != tt.expectErr {
+			t.Errorf("expected v%d, active v%d: expected error %v, got %v", tt.expected, tt.active, tt.expectErr, err)
+		}
+	}
+}
+
+func TestMissingKernelConfigs(t *testing.T) {
+	content := `#
+# Automatically generated file; DO NOT EDIT.
+#
+CONFIG_SECCOMP=y
+# CONFIG_SECCOMP_FILTER is not set
+CONFIG_VETH=m
+`
+	missing := missingKernelConfigs(content, []string{"CONFIG_SECCOMP", "CONFIG_SECCOMP_FILTER", "CONFIG_VETH"})
+	if !reflect.DeepEqual(missing, []string{"CONFIG_SECCOMP_FILTER"}) {
+		t.Errorf("expected CONFIG_SECCOMP_FILTER missing, got %v", missing)
+	}
+}
+
+func TestEvaluateCapacityBudgets(t *testing.T) {
+	tests := []struct {
+		name           string
+		values         map[string]int
+		expectWarnings int
+		bottleneck     string
+	}{
+		{
+			name:   "sufficient",
+			values: map[string]int{"kernel.pid_max": 4194304, "fs.file-max": 9223372036854775807, "net.netfilter.nf_conntrack_max": 262144},
+		},
+		{
+			name:   "conntrack unavailable",
+			values: map[string]int{"kernel.pid_max": 4194304},
+		},
+		{
+			name:           "pid_max and conntrack insufficient",
+			values:         map[string]int{"kernel.pid_max": 16384, "fs.file-max": 9223372036854775807, "net.netfilter.nf_conntrack_max": 131072},
+			expectWarnings: 3,
+			bottleneck:     "kernel.pid_max",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings := evaluateCapacityBudgets(defaultMaxPods, tt.values)
+			if len(warnings) != tt.expectWarnings {
+				t.Fatalf("expected %d warnings, got %v", tt.expectWarnings, warnings)
+			}
+			if tt.bottleneck != "" && !strings.HasPrefix(warnings[len(warnings)-1].Error(), tt.bottleneck+" is the bottleneck") {
+				t.Errorf("expected bottleneck %s, got %v", tt.bottleneck, warnings[len(warnings)-1])
+			}
+		})
+	}
+}
@@ -0,0 +1,492 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/klog/v2"
+
+	"github.com/openyurtio/openyurt/pkg/yurtadm/constants"
+)
+
+const (
+	defaultResolvConf = "/etc/resolv.conf"
+
+	kubeletConfigFile = "config.yaml"
+	// defaultContainerLogMaxSize is the default of containerLogMaxSize in kubelet
+	defaultContainerLogMaxSize = "10Mi"
+)
+
+// StaleKubeletMountsCheck verifies that no mount is left under the kubelet directory by a previous
+// install, such mounts can't be removed and block the join.
+type StaleKubeletMountsCheck struct {
+	// KubeletDir defaults to /var/lib/kubelet.
+	KubeletDir string
+}
+
+func (StaleKubeletMountsCheck) Name() string {
+	return "StaleKubeletMounts"
+}
+
+func (skc StaleKubeletMountsCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"kubeletDir": skc.KubeletDir}
+}
+
+func (skc StaleKubeletMountsCheck) Check() (warnings, errorList []error) {
+	dir := skc.KubeletDir
+	if dir == "" {
+		dir = constants.KubeletWorkdir
+	}
+	klog.V(1).Infof("validating no mount is left under %s", dir)
+
+	mounts, err := readMounts()
+	if err != nil {
+		return nil, []error{errors.Wrapf(err, "unable to read %s", procMountsPath)}
+	}
+
+	if stale := staleMountPoints(mounts, dir); len(stale) != 0 {
+		return nil, []error{errors.Errorf("found mounts left under %s: %s, please clean them up with 'umount' before joining", dir, strings.Join(stale, ", "))}
+	}
+	return nil, nil
+}
+
+// staleMountPoints returns the mount points strictly under dir, a mount on dir itself
+// (e.g. a dedicated kubelet partition) is not stale.
+func staleMountPoints(mounts []mountEntry, dir string) []string {
+	var stale []string
+	for _, m := range mounts {
+		if filepath.Clean(m.MountPoint) != filepath.Clean(dir) && isSubPath(m.MountPoint, dir) {
+			stale = append(stale, m.MountPoint)
+		}
+	}
+	return stale
+}
+
+// KubeletResolvConfPathCheck verifies that the file kubelet is configured with by --resolv-conf
+// exists and is not empty, otherwise pods get no DNS. For the default /etc/resolv.conf,
+// problems are only reported as warnings.
+type KubeletResolvConfPathCheck struct {
+	// Path defaults to /etc/resolv.conf.
+	Path string
+}
+
+func (KubeletResolvConfPathCheck) Name() string {
+	return "KubeletResolvConfPath"
+}
+
+func (krc KubeletResolvConfPathCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"path": krc.Path}
+}
+
+func (krc KubeletResolvConfPathCheck) Check() (warnings, errorList []error) {
+	path := krc.Path
+	if path == "" {
+		path = defaultResolvConf
+	}
+	klog.V(1).Infof("validating kubelet resolv-conf %s", path)
+
+	var problem error
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		problem = errors.Wrapf(err, "resolv-conf %s configured for kubelet is not accessible", path)
+	case info.IsDir():
+		problem = errors.Errorf("resolv-conf %s configured for kubelet is a directory", path)
+	case info.Size() == 0:
+		problem = errors.Errorf("resolv-conf %s configured for kubelet is empty, pods will get no DNS", path)
+	default:
+		return nil, nil
+	}
+
+	if path == defaultResolvConf {
+		return []error{problem}, nil
+	}
+	return nil, []error{problem}
+}
+
+// KubeletCertRotationCheck verifies that kubelet has client certificate rotation (rotateCertificates)
+// and serving certificate bootstrap (serverTLSBootstrap) enabled when the cluster expects them,
+// e.g. for secure metrics scraping. A missing kubelet config passes, as it's written at join.
+type KubeletCertRotationCheck struct {
+	// KubeletConfigPath defaults to /var/lib/kubelet/config.yaml.
+	KubeletConfigPath         string
+	RequireRotateCertificates bool
+	RequireServerTLSBootstrap bool
+}
+
+func (KubeletCertRotationCheck) Name() string {
+	return "KubeletCertRotation"
+}
+
+func (kcc KubeletCertRotationCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"kubeletConfigPath":         kcc.KubeletConfigPath,
+		"requireRotateCertificates": kcc.RequireRotateCertificates,
+		"requireServerTLSBootstrap": kcc.RequireServerTLSBootstrap,
+	}
+}
+
+func (kcc KubeletCertRotationCheck) Check() (warnings, errorList []error) {
+	path := kubeletConfigPath(kcc.KubeletConfigPath)
+	klog.V(1).Infof("validating certificate rotation in kubelet config %s", path)
+
+	config, err := loadKubeletConfig(path)
+	if err != nil {
+		return []error{err}, nil
+	} else if config == nil {
+		klog.V(1).Infof("kubelet config %s doesn't exist, skipping", path)
+		return nil, nil
+	}
+	if kcc.RequireRotateCertificates && !config.RotateCertificates {
+		warnings = append(warnings, errors.Errorf("rotateCertificates is not enabled in kubelet config %s, the client certificate will expire", path))
+	}
+	if kcc.RequireServerTLSBootstrap && !config.ServerTLSBootstrap {
+		warnings = append(warnings, errors.Errorf("serverTLSBootstrap is not enabled in kubelet config %s, the serving certificate is self-signed", path))
+	}
+	return warnings, nil
+}
+
+// ContainerLogRotationCheck warns when containerLogMaxSize is not set in the kubelet config, so that
+// the kubelet default is relied on, which may fill up the small disks of edge nodes.
+// A missing kubelet config passes, as it's written at join.
+type ContainerLogRotationCheck struct {
+	// KubeletConfigPath defaults to /var/lib/kubelet/config.yaml.
+	KubeletConfigPath string
+	// MaxSizeDefault is the containerLogMaxSize kubelet falls back to, defaults to 10Mi.
+	MaxSizeDefault string
+}
+
+func (ContainerLogRotationCheck) Name() string {
+	return "ContainerLogRotation"
+}
+
+func (clc ContainerLogRotationCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"kubeletConfigPath": clc.KubeletConfigPath,
+		"maxSizeDefault":    clc.MaxSizeDefault,
+	}
+}
+
+func (clc ContainerLogRotationCheck) Check() (warnings, errorList []error) {
+	path := kubeletConfigPath(clc.KubeletConfigPath)
+	klog.V(1).Infof("validating container log rotation in kubelet config %s", path)
+
+	config, err := loadKubeletConfig(path)
+	if err != nil {
+		return []error{err}, nil
+	} else if config == nil {
+		klog.V(1).Infof("kubelet config %s doesn't exist, skipping", path)
+		return nil, nil
+	}
+	if config.ContainerLogMaxSize == "" {
+		maxSize := clc.MaxSizeDefault
+		if maxSize == "" {
+			maxSize = defaultContainerLogMaxSize
+		}
+		warnings = append(warnings, errors.Errorf("containerLogMaxSize is not set in kubelet config %s, the default of %s per log file is used, which may fill up the disk of constrained nodes", path, maxSize))
+	}
+	return warnings, nil
+}
+
+// ReservedResourcesCheck verifies that kubeReserved and systemReserved in the kubelet config don't
+// exceed the CPU and memory of the node, which makes the allocatable negative and the node unschedulable.
+// A missing kubelet config passes, as it's written at join.
+type ReservedResourcesCheck struct {
+	// KubeletConfigPath defaults to /var/lib/kubelet/config.yaml.
+	KubeletConfigPath string
+}
+
+func (ReservedResourcesCheck) Name() string {
+	return "ReservedResources"
+}
+
+func (rrc ReservedResourcesCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"kubeletConfigPath": rrc.KubeletConfigPath}
+}
+
+func (rrc ReservedResourcesCheck) Check() (warnings, errorList []error) {
+	path := kubeletConfigPath(rrc.KubeletConfigPath)
+	klog.V(1).Infof("validating reserved resources in kubelet config %s", path)
+
+	config, err := loadKubeletConfig(path)
+	if err != nil {
+		return []error{err}, nil
+	} else if config == nil {
+		klog.V(1).Infof("kubelet config %s doesn't exist, skipping", path)
+		return nil, nil
+	}
+
+	memTotal, err := readMemTotal()
+	if err != nil {
+		return []error{errors.Wrap(err, "failed to detect memory of the node")}, nil
+	}
+	capacity := v1.ResourceList{
+		v1.ResourceCPU:    *resource.NewQuantity(int64(runtime.NumCPU()), resource.DecimalSI),
+		v1.ResourceMemory: *resource.NewQuantity(int64(memTotal), resource.BinarySI),
+	}
+	return nil, validateReservedResources(config.KubeReserved, config.SystemReserved, capacity)
+}
+
+// validateReservedResources returns an error for every resource of capacity whose sum of
+// kubeReserved and systemReserved is not less than the capacity.
+func validateReservedResources(kubeReserved, systemReserved map[string]string, capacity v1.ResourceList) (errorList []error) {
+	for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+		reserved := resource.Quantity{}
+		for _, reservation := range []map[string]string{kubeReserved, systemReserved} {
+			value, ok := reservation[string(name)]
+			if !ok {
+				continue
+			}
+			quantity, err := resource.ParseQuantity(value)
+			if err != nil {
+				errorList = append(errorList, errors.Wrapf(err, "invalid reserved %s %q", name, value))
+				continue
+			}
+			reserved.Add(quantity)
+		}
+		total := capacity[name]
+		if reserved.Cmp(total) >= 0 {
+			errorList = append(errorList, errors.Errorf("reserved %s %s (kubeReserved + systemReserved) is not less than the capacity %s of the node, the node will be unschedulable", name, reserved.String(), total.String()))
+		}
+	}
+	return errorList
+}
+
+// StaticPodPathCheck verifies that the static pod directory is writable and watched by kubelet,
+// i.e. it's the staticPodPath of the kubelet config. A missing kubelet config only checks the directory.
+type StaticPodPathCheck struct {
+	// Path defaults to /etc/kubernetes/manifests.
+	Path string
+	// KubeletConfigPath defaults to /var/lib/kubelet/config.yaml.
+	KubeletConfigPath string
+}
+
+func (StaticPodPathCheck) Name() string {
+	return "StaticPodPath"
+}
+
+func (spc StaticPodPathCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"path":              spc.Path,
+		"kubeletConfigPath": spc.KubeletConfigPath,
+	}
+}
+
+func (spc StaticPodPathCheck) Check() (warnings, errorList []error) {
+	path := spc.Path
+	if path == "" {
+		path = constants.StaticPodPath
+	}
+	configPath := kubeletConfigPath(spc.KubeletConfigPath)
+	klog.V(1).Infof("validating static pod path %s is writable and set in kubelet config %s", path, configPath)
+
+	if err := isDirWritable(path); err != nil {
+		errorList = append(errorList, errors.Wrapf(err, "static pod path %s is not writable", path))
+	}
+
+	config, err := loadKubeletConfig(configPath)
+	if err != nil {
+		return []error{err}, errorList
+	} else if config == nil {
+		klog.V(1).Infof("kubelet config %s doesn't exist, skipping", configPath)
+		return nil, errorList
+	}
+	if config.StaticPodPath == "" {
+		errorList = append(errorList, errors.Errorf("staticPodPath is not set in kubelet config %s, static pods in %s will not be run", configPath, path))
+	} else if filepath.Clean(config.StaticPodPath) != filepath.Clean(path) {
+		errorList = append(errorList, errors.Errorf("staticPodPath in kubelet config %s is %s instead of %s, static pods in %s will not be run", configPath, config.StaticPodPath, path, path))
+	}
+	return nil, errorList
+}
+
+// kubeletCgroupV1Controllers are the cgroup v1 controllers whose hierarchies must contain the kubelet cgroup root.
+var kubeletCgroupV1Controllers = []string{"cpu", "memory", "pids"}
+
+// KubeletCgroupRootCheck warns when the cgroup root of kubelet (--cgroup-root) doesn't exist in the
+// active cgroup hierarchy. systemd usually creates it, but static configs may point to wrong ones.
+type KubeletCgroupRootCheck struct {
+	// CgroupRoot is a cgroupfs path, e.g. /kubepods, the check is skipped when it's empty or /.
+	CgroupRoot string
+}
+
+func (KubeletCgroupRootCheck) Name() string {
+	return "KubeletCgroupRoot"
+}
+
+func (kcc KubeletCgroupRootCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"cgroupRoot": kcc.CgroupRoot}
+}
+
+func (kcc KubeletCgroupRootCheck) Check() (warnings, errorList []error) {
+	if kcc.CgroupRoot == "" || filepath.Clean(kcc.CgroupRoot) == "/" {
+		return nil, nil
+	}
+	klog.V(1).Infof("validating kubelet cgroup root %s exists", kcc.CgroupRoot)
+
+	if missing := missingCgroupPaths(cgroupRootDir, kcc.CgroupRoot, isCgroupV2Unified()); len(missing) != 0 {
+		return []error{errors.Errorf("kubelet cgroup root %s doesn't exist at %s, please create it or fix --cgroup-root of kubelet", kcc.CgroupRoot, strings.Join(missing, ", "))}, nil
+	}
+	return nil, nil
+}
+
+// missingCgroupPaths returns the paths of cgroup under base that don't exist, the unified one for
+// cgroup v2, or the ones of every controller in kubeletCgroupV1Controllers otherwise.
+func missingCgroupPaths(base, cgroup string, unified bool) []string {
+	paths := []string{filepath.Join(base, cgroup)}
+	if !unified {
+		paths = nil
+		for _, controller := range kubeletCgroupV1Controllers {
+			paths = append(paths, filepath.Join(base, controller, cgroup))
+		}
+	}
+	var missing []string
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			missing = append(missing, path)
+		}
+	}
+	return missing
+}
+
+const (
+	// defaultMaxOOMScoreAdj is the oom_score_adj above which kubelet is considered unprotected
+	defaultMaxOOMScoreAdj = -500
+)
+
+// OOMScoreCheck warns when kubelet is not protected from the OOM killer, i.e. its oom_score_adj is
+// above MaxScoreAdj. The running kubelet is inspected when PID is set, otherwise OOMScoreAdjust of
+// the kubelet systemd unit and its drop-ins, as kubelet adjusts itself by --oom-score-adj when unset.
+type OOMScoreCheck struct {
+	PID int
+	// MaxScoreAdj defaults to -500.
+	MaxScoreAdj int
+}
+
+func (OOMScoreCheck) Name() string {
+	return "OOMScore"
+}
+
+func (osc OOMScoreCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"pid":         osc.PID,
+		"maxScoreAdj": osc.MaxScoreAdj,
+	}
+}
+
+func (osc OOMScoreCheck) Check() (warnings, errorList []error) {
+	maxScoreAdj := osc.MaxScoreAdj
+	if maxScoreAdj == 0 {
+		maxScoreAdj = defaultMaxOOMScoreAdj
+	}
+
+	if osc.PID != 0 {
+		path := filepath.Join("/proc", strconv.Itoa(osc.PID), "oom_score_adj")
+		klog.V(1).Infof("validating oom_score_adj of kubelet in %s", path)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return []error{errors.Wrapf(err, "unable to read %s", path)}, nil
+		}
+		scoreAdj, err := strconv.Atoi(strings.TrimSpace(string(content)))
+		if err != nil {
+			return []error{errors.Wrapf(err, "failed to parse %s", path)}, nil
+		}
+		if scoreAdj > maxScoreAdj {
+			return []error{errors.Errorf("oom_score_adj of kubelet (pid %d) is %d, it may be killed under memory pressure, please set --oom-score-adj of kubelet to at most %d", osc.PID, scoreAdj, maxScoreAdj)}, nil
+		}
+		return nil, nil
+	}
+
+	klog.V(1).Infoln("validating OOMScoreAdjust of the kubelet unit")
+	units := []string{constants.KubeletServiceFilepath}
+	for _, dir := range []string{filepath.Dir(constants.KubeletSvcPath), filepath.Dir(constants.KubeletServiceConfPath)} {
+		dropIns, _ := filepath.Glob(filepath.Join(dir, "*.conf"))
+		units = append(units, dropIns...)
+	}
+	var scoreAdj int
+	var source string
+	for _, unit := range units {
+		content, err := os.ReadFile(unit)
+		if err != nil {
+			continue
+		}
+		if value, ok := parseOOMScoreAdjust(string(content)); ok {
+			scoreAdj, source = value, unit
+		}
+	}
+	if source != "" && scoreAdj > maxScoreAdj {
+		return []error{errors.Errorf("OOMScoreAdjust of kubelet is %d in %s, it may be killed under memory pressure, please set it to at most %d", scoreAdj, source, maxScoreAdj)}, nil
+	}
+	return nil, nil
+}
+
+// parseOOMScoreAdjust returns the last OOMScoreAdjust in the content of a systemd unit file.
+func parseOOMScoreAdjust(content string) (int, bool) {
+	var scoreAdj int
+	var found bool
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "OOMScoreAdjust=") {
+			continue
+		}
+		if v, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "OOMScoreAdjust="))); err == nil {
+			scoreAdj, found = v, true
+		}
+	}
+	return scoreAdj, found
+}
+
+// kubeletConfig is the subset of the kubelet config file used by the checks.
+type kubeletConfig struct {
+	RotateCertificates  bool              `yaml:"rotateCertificates"`
+	ServerTLSBootstrap  bool              `yaml:"serverTLSBootstrap"`
+	ContainerLogMaxSize string            `yaml:"containerLogMaxSize"`
+	KubeReserved        map[string]string `yaml:"kubeReserved"`
+	SystemReserved      map[string]string `yaml:"systemReserved"`
+	StaticPodPath       string            `yaml:"staticPodPath"`
+}
+
+// kubeletConfigPath returns path, or the default kubelet config path if path is empty.
+func kubeletConfigPath(path string) string {
+	if path == "" {
+		return filepath.Join(constants.KubeletWorkdir, kubeletConfigFile)
+	}
+	return path
+}
+
+// loadKubeletConfig parses the kubelet config file, nil is returned if it doesn't exist.
+func loadKubeletConfig(path string) (*kubeletConfig, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "unable to read kubelet config %s", path)
+	}
+	config := &kubeletConfig{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse kubelet config %s", path)
+	}
+	return config, nil
+}
@@ -0,0 +1,333 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestStaleMountPoints(t *testing.T) {
+	mounts := []mountEntry{
+		{Device: "/dev/sda1", MountPoint: "/"},
+		{Device: "/dev/sdb1", MountPoint: "/var/lib/kubelet"},
+		{Device: "/dev/sdc1", MountPoint: "/var/lib/kubelet-data"},
+		{Device: "tmpfs", MountPoint: "/var/lib/kubelet/pods/1234/volumes/kubernetes.io~projected/kube-api-access"},
+	}
+	tests := []struct {
+		name     string
+		dir      string
+		expected []string
+	}{
+		{
+			name:     "dedicated kubelet partition",
+			dir:      "/var/lib/kubelet",
+			expected: []string{"/var/lib/kubelet/pods/1234/volumes/kubernetes.io~projected/kube-api-access"},
+		},
+		{
+			name:     "trailing slash",
+			dir:      "/var/lib/kubelet/",
+			expected: []string{"/var/lib/kubelet/pods/1234/volumes/kubernetes.io~projected/kube-api-access"},
+		},
+		{
+			name: "no mount under dir",
+			dir:  "/var/lib/kubelet/plugins",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if stale := staleMountPoints(mounts, tt.dir); !reflect.DeepEqual(stale, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, stale)
+			}
+		})
+	}
+}
+
+func TestKubeletResolvConfPathCheck(t *testing.T) {
+	dir := t.TempDir()
+	resolvConf := filepath.Join(dir, "resolv.conf")
+	if err := os.WriteFile(resolvConf, []byte("nameserver 10.0.0.2\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	empty := filepath.Join(dir, "empty.conf")
+	if err := os.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		expectErrors int
+	}{
+		{name: "valid", path: resolvConf},
+		{name: "empty", path: empty, expectErrors: 1},
+		{name: "directory", path: dir, expectErrors: 1},
+		{name: "missing", path: filepath.Join(dir, "missing.conf"), expectErrors: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, errs := KubeletResolvConfPathCheck{Path: tt.path}.Check()
+			if len(warnings) != 0 || len(errs) != tt.expectErrors {
+				t.Errorf("expected no warnings and %d errors, got warnings %v, errors %v", tt.expectErrors, warnings, errs)
+			}
+		})
+	}
+}
+
+func TestKubeletCertRotationCheck(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         string
+		expectWarnings int
+	}{
+		{
+			name:   "missing config",
+			config: "",
+		},
+		{
+			name:   "rotation enabled",
+			config: "kind: KubeletConfiguration\nrotateCertificates: true\nserverTLSBootstrap: true\n",
+		},
+		{
+			name:           "rotation disabled",
+			config:         "kind: KubeletConfiguration\nrotateCertificates: false\n",
+			expectWarnings: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if tt.config != "" {
+				if err := os.WriteFile(path, []byte(tt.config), 0644); err != nil {
+					t.Fatalf("failed to write file: %v", err)
+				}
+			}
+			check := KubeletCertRotationCheck{KubeletConfigPath: path, RequireRotateCertificates: true, RequireServerTLSBootstrap: true}
+			warnings, errs := check.Check()
+			if len(warnings) != tt.expectWarnings || len(errs) != 0 {
+				t.Errorf("expected %d warnings and no errors, got warnings %v, errors %v", tt.expectWarnings, warnings, errs)
+			}
+		})
+	}
+}
+
+func TestContainerLogRotationCheck(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         string
+		expectWarnings int
+	}{
+		{
+			name:   "missing config",
+			config: "",
+		},
+		{
+			name:   "max size set",
+			config: "kind: KubeletConfiguration\ncontainerLogMaxSize: 5Mi\ncontainerLogMaxFiles: 3\n",
+		},
+		{
+			name:           "max size unset",
+			config:         "kind: KubeletConfiguration\n",
+			expectWarnings: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if tt.config != "" {
+				if err := os.WriteFile(path, []byte(tt.config), 0644); err != nil {
+					t.Fatalf("failed to write file: %v", err)
+				}
+			}
+			warnings, errs := ContainerLogRotationCheck{KubeletConfigPath: path}.Check()
+			if len(warnings) != tt.expectWarnings || len(errs) != 0 {
+				t.Errorf("expected %d warnings and no errors, got warnings %v, errors %v", tt.expectWarnings, warnings, errs)
+			}
+		})
+	}
+}
+
+func TestValidateReservedResources(t *testing.T) {
+	capacity := v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse("2"),
+		v1.ResourceMemory: resource.MustParse("4Gi"),
+	}
+	tests := []struct {
+		name           string
+		kubeReserved   map[string]string
+		systemReserved map[string]string
+		expectErrs     int
+	}{
+		{
+			name: "nothing reserved",
+		},
+		{
+			name:           "within capacity",
+			kubeReserved:   map[string]string{"cpu": "500m", "memory": "1Gi"},
+			systemReserved: map[string]string{"cpu": "500m", "memory": "512Mi"},
+		},
+		{
+			name:           "cpu over allocated",
+			kubeReserved:   map[string]string{"cpu": "1500m"},
+			systemReserved: map[string]string{"cpu": "1"},
+			expectErrs:     1,
+		},
+		{
+			name:           "cpu and memory over allocated",
+			kubeReserved:   map[string]string{"cpu": "2", "memory": "3Gi"},
+			systemReserved: map[string]string{"memory": "2Gi"},
+			expectErrs:     2,
+		},
+		{
+			name:         "invalid quantity",
+			kubeReserved: map[string]string{"memory": "lots"},
+			expectErrs:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errs := validateReservedResources(tt.kubeReserved, tt.systemReserved, capacity); len(errs) != tt.expectErrs {
+				t.Errorf("expected %d errors, got %v", tt.expectErrs, errs)
+			}
+		})
+	}
+}
+
+func TestStaticPodPathCheck(t *testing.T) {
+	manifests := t.TempDir()
+	tests := []struct {
+		name       string
+		path       string
+		config     string
+		expectErrs int
+	}{
+		{
+			name: "missing config",
+			path: manifests,
+		},
+		{
+			name:   "watched",
+			path:   manifests,
+			config: "kind: KubeletConfiguration\nstaticPodPath: " + manifests + "/\n",
+		},
+		{
+			name:       "not watched",
+			path:       manifests,
+			config:     "kind: KubeletConfiguration\n",
+			expectErrs: 1,
+		},
+		{
+			name:       "mismatch",
+			path:       manifests,
+			config:     "kind: KubeletConfiguration\nstaticPodPath: /etc/kubernetes/manifests\n",
+			expectErrs: 1,
+		},
+		{
+			name:       "missing dir",
+			path:       filepath.Join(manifests, "missing"),
+			expectErrs: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if tt.config != "" {
+				if err := os.WriteFile(path, []byte(tt.config), 0644); err != nil {
+					t.Fatalf("failed to write file: %v", err)
+				}
+			}
+			warnings, errs := StaticPodPathCheck{Path: tt.path, KubeletConfigPath: path}.Check()
+			if len(warnings) != 0 || len(errs) != tt.expectErrs {
+				t.Errorf("expected no warnings and %d errors, got warnings %v, errors %v", tt.expectErrs, warnings, errs)
+			}
+		})
+	}
+}
+
+func TestParseMemTotal(t *testing.T) {
+	total, err := parseMemTotal("MemTotal:       16315128 kB\nMemFree:         1234567 kB\n")
+	if err != nil || total != 16315128*1024 {
+		t.Errorf("expected %d, got %d, error %v", 16315128*1024, total, err)
+	}
+	if _, err := parseMemTotal("MemFree:  1234567 kB\n"); err == nil {
+		t.Errorf("expected error for missing MemTotal")
+	}
+}
+
+func TestMissingCgroupPaths(t *testing.T) {
+	base := t.TempDir()
+	for _, dir := range []string{"kubepods", "cpu/kubepods", "memory/kubepods"} {
+		if err := os.MkdirAll(filepath.Join(base, dir), 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", dir, err)
+		}
+	}
+
+	if missing := missingCgroupPaths(base, "/kubepods", true); len(missing) != 0 {
+		t.Errorf("expected no missing path for cgroup v2, got %v", missing)
+	}
+	if missing := missingCgroupPaths(base, "/kubepods", false); len(missing) != 1 || missing[0] != filepath.Join(base, "pids", "kubepods") {
+		t.Errorf("expected pids path missing for cgroup v1, got %v", missing)
+	}
+	if missing := missingCgroupPaths(base, "/edge.slice", true); len(missing) != 1 {
+		t.Errorf("expected missing path for absent cgroup, got %v", missing)
+	}
+}
+
+func TestParseOOMScoreAdjust(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected int
+		found    bool
+	}{
+		{
+			name:     "set",
+			content:  "[Service]\nOOMScoreAdjust=-999\nExecStart=/usr/bin/kubelet\n",
+			expected: -999,
+			found:    true,
+		},
+		{
+			name:     "last one wins",
+			content:  "[Service]\nOOMScoreAdjust=-999\nOOMScoreAdjust=0\n",
+			expected: 0,
+			found:    true,
+		},
+		{
+			name:    "unset",
+			content: "[Service]\nExecStart=/usr/bin/kubelet\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, found := parseOOMScoreAdjust(tt.content)
+			if value != tt.expected || found != tt.found {
+				t.Errorf("expected (%d, %v), got (%d, %v)", tt.expected, tt.found, value, found)
+			}
+		})
+	}
+}
@@ -0,0 +1,1516 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+	utilsexec "k8s.io/utils/exec"
+
+	"github.com/openyurtio/openyurt/pkg/yurtadm/constants"
+)
+
+const (
+	clusterDNSTestName = "kubernetes.default.svc.cluster.local"
+	clusterDNSTimeout  = 5 * time.Second
+
+	loopbackInterface = "lo"
+
+	yurtHubHealthTimeout = 5 * time.Second
+
+	cloudMetadataTimeout = 3 * time.Second
+
+	cniConfDir = "/etc/cni/net.d"
+
+	nsswitchConf = "/etc/nsswitch.conf"
+
+	procNetRoute = "/proc/net/route"
+	procNetARP   = "/proc/net/arp"
+	sysClassNet  = "/sys/class/net"
+
+	registryMirrorTimeout = 5 * time.Second
+
+	httpEndpointTimeout = 5 * time.Second
+
+	stunTimeout = 3 * time.Second
+	// stunMagicCookie, stunBindingRequest, stunBindingSuccess and the attribute types are defined in RFC 5389
+	stunMagicCookie          = 0x2112A442
+	stunBindingRequest       = 0x0001
+	stunBindingSuccess       = 0x0101
+	stunAttrMappedAddress    = 0x0001
+	stunAttrXORMappedAddress = 0x0020
+	stunHeaderLength         = 20
+)
+
+// ClusterDNSCheck verifies that in-cluster service names can be resolved through the cluster DNS service.
+// It only makes sense after the node has joined and CoreDNS is up, so it should be run as a post check.
+type ClusterDNSCheck struct {
+	DNSService string
+}
+
+func (ClusterDNSCheck) Name() string {
+	return "ClusterDNS"
+}
+
+func (cdc ClusterDNSCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"dnsService": cdc.DNSService}
+}
+
+func (cdc ClusterDNSCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infof("validating resolution of %s against cluster dns %s", clusterDNSTestName, cdc.DNSService)
+
+	if net.ParseIP(cdc.DNSService) == nil {
+		return nil, []error{errors.Errorf("cluster dns service address %q is not a valid IP", cdc.DNSService)}
+	}
+
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			d := net.Dialer{Timeout: clusterDNSTimeout}
+			return d.DialContext(ctx, network, net.JoinHostPort(cdc.DNSService, "53"))
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), clusterDNSTimeout)
+	defer cancel()
+
+	addrs, err := resolver.LookupHost(ctx, clusterDNSTestName)
+	if err != nil {
+		return nil, []error{errors.Wrapf(err, "failed to resolve %s against cluster dns %s", clusterDNSTestName, cdc.DNSService)}
+	}
+	if len(addrs) == 0 {
+		return nil, []error{errors.Errorf("cluster dns %s returned no address for %s", cdc.DNSService, clusterDNSTestName)}
+	}
+	klog.V(1).Infof("%s resolved to %v", clusterDNSTestName, addrs)
+	return nil, nil
+}
+
+// LoopbackCheck verifies that the loopback interface exists, is up and has 127.0.0.1/8 assigned,
+// and also ::1/128 when IPv6 is in use on the node.
+type LoopbackCheck struct{}
+
+func (LoopbackCheck) Name() string {
+	return "Loopback"
+}
+
+func (LoopbackCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infoln("validating loopback interface")
+
+	lo, err := net.InterfaceByName(loopbackInterface)
+	if err != nil {
+		return nil, []error{errors.Wrapf(err, "loopback interface %s not found", loopbackInterface)}
+	}
+	if lo.Flags&net.FlagUp == 0 {
+		errorList = append(errorList, errors.Errorf("loopback interface %s is down", loopbackInterface))
+	}
+
+	addrs, err := lo.Addrs()
+	if err != nil {
+		return nil, append(errorList, errors.Wrapf(err, "unable to get addresses of loopback interface %s", loopbackInterface))
+	}
+	if !hasIPNet(addrs, "127.0.0.1/8") {
+		errorList = append(errorList, errors.Errorf("loopback interface %s doesn't have 127.0.0.1/8 assigned", loopbackInterface))
+	}
+	if ipv6InUse() && !hasIPNet(addrs, "::1/128") {
+		errorList = append(errorList, errors.Errorf("IPv6 is in use but loopback interface %s doesn't have ::1/128 assigned", loopbackInterface))
+	}
+	return nil, errorList
+}
+
+// hasIPNet returns true if addrs contains the given address in CIDR notation.
+func hasIPNet(addrs []net.Addr, cidr string) bool {
+	for _, addr := range addrs {
+		if addr.String() == cidr {
+			return true
+		}
+	}
+	return false
+}
+
+// ipv6InUse returns true if any non-loopback interface has a global unicast IPv6 address.
+func ipv6InUse() bool {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return false
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if ok && ipNet.IP.To4() == nil && ipNet.IP.IsGlobalUnicast() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AdvertiseAddressCheck verifies that the advertise address is a usable IP assigned to a local interface.
+type AdvertiseAddressCheck struct {
+	Address string
+}
+
+func (AdvertiseAddressCheck) Name() string {
+	return "AdvertiseAddress"
+}
+
+func (aac AdvertiseAddressCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"address": aac.Address}
+}
+
+func (aac AdvertiseAddressCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infof("validating advertise address %s", aac.Address)
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, []error{errors.Wrap(err, "unable to list interface addresses")}
+	}
+	if err := validateAdvertiseAddress(aac.Address, addrs); err != nil {
+		return nil, []error{err}
+	}
+	return nil, nil
+}
+
+// validateAdvertiseAddress verifies that address is a usable unicast IP assigned to one of addrs.
+func validateAdvertiseAddress(address string, addrs []net.Addr) error {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return errors.Errorf("advertise address %q is not a valid IP", address)
+	}
+	switch {
+	case ip.IsUnspecified():
+		return errors.Errorf("advertise address %s is unspecified", ip)
+	case ip.IsLoopback():
+		return errors.Errorf("advertise address %s is a loopback address", ip)
+	case ip.IsLinkLocalUnicast():
+		return errors.Errorf("advertise address %s is a link-local address", ip)
+	}
+
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return nil
+		}
+	}
+	return errors.Errorf("advertise address %s is not assigned to any local interface", ip)
+}
+
+// PortSpec describes a port required on the node.
+type PortSpec struct {
+	Port int `yaml:"port"`
+	// Protocol is either tcp or udp, defaults to tcp.
+	Protocol string `yaml:"protocol,omitempty"`
+	Label    string `yaml:"label,omitempty"`
+}
+
+func (ps PortSpec) protocol() string {
+	if ps.Protocol == "" {
+		return "tcp"
+	}
+	return strings.ToLower(ps.Protocol)
+}
+
+func (ps PortSpec) String() string {
+	if ps.Label != "" {
+		return fmt.Sprintf("%d/%s(%s)", ps.Port, ps.protocol(), ps.Label)
+	}
+	return fmt.Sprintf("%d/%s", ps.Port, ps.protocol())
+}
+
+// PortRangeCheck ensures all the given ports are available in one pass, and reports
+// the occupied ports together with the processes owning them.
+type PortRangeCheck struct {
+	Ports []PortSpec
+}
+
+func (PortRangeCheck) Name() string {
+	return "PortRange"
+}
+
+func (prc PortRangeCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"ports": prc.Ports}
+}
+
+func (prc PortRangeCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infof("validating availability of ports %v", prc.Ports)
+
+	var occupied []string
+	for _, spec := range prc.Ports {
+		inUse, err := isPortInUse(spec.protocol(), spec.Port)
+		if err != nil {
+			warnings = append(warnings, err)
+			continue
+		}
+		if !inUse {
+			continue
+		}
+		if owners := findPortOwners(spec.protocol(), spec.Port); len(owners) != 0 {
+			occupied = append(occupied, fmt.Sprintf("%s used by %s", spec, strings.Join(owners, ",")))
+		} else {
+			occupied = append(occupied, spec.String())
+		}
+	}
+	if len(occupied) != 0 {
+		errorList = append(errorList, errors.Errorf("ports are in use: %s", strings.Join(occupied, "; ")))
+	}
+	return warnings, errorList
+}
+
+// isPortInUse tries to listen on the port to find out whether it is in use.
+func isPortInUse(protocol string, port int) (bool, error) {
+	addr := fmt.Sprintf(":%d", port)
+	switch protocol {
+	case "tcp":
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return true, nil
+		}
+		ln.Close()
+	case "udp":
+		conn, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			return true, nil
+		}
+		conn.Close()
+	default:
+		return false, errors.Errorf("unsupported protocol %q for port %d", protocol, port)
+	}
+	return false, nil
+}
+
+// findPortOwners returns the processes (as name[pid]) holding sockets bound to the port,
+// by matching the socket inodes in /proc/net/{tcp,udp}{,6} against the fds in /proc/<pid>/fd.
+// It is best effort, nothing is returned when /proc can't be read.
+func findPortOwners(protocol string, port int) []string {
+	inodes := map[string]bool{}
+	for _, file := range []string{protocol, protocol + "6"} {
+		content, err := os.ReadFile(filepath.Join("/proc/net", file))
+		if err != nil {
+			continue
+		}
+		for _, inode := range parseProcNetInodes(string(content), protocol, port) {
+			inodes[inode] = true
+		}
+	}
+	if len(inodes) == 0 {
+		return nil
+	}
+
+	var owners []string
+	fdDirs, _ := filepath.Glob("/proc/[0-9]*/fd")
+	for _, fdDir := range fdDirs {
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		pidDir := filepath.Dir(fdDir)
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			if inodes[strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")] {
+				comm, _ := os.ReadFile(filepath.Join(pidDir, "comm"))
+				owners = append(owners, fmt.Sprintf("%s[%s]", strings.TrimSpace(string(comm)), filepath.Base(pidDir)))
+				break
+			}
+		}
+	}
+	return owners
+}
+
+// parseProcNetInodes returns the inodes of the sockets bound to port in the content of
+// /proc/net/{tcp,udp}{,6}. Only listening sockets are considered for tcp.
+func parseProcNetInodes(content, protocol string, port int) []string {
+	var inodes []string
+	for _, line := range procNetSocketLines(content, protocol, port) {
+		inodes = append(inodes, strings.Fields(line)[9])
+	}
+	return inodes
+}
+
+// procNetSocketLines returns the trimmed lines of the sockets bound to port in the content of
+// /proc/net/{tcp,udp}{,6}. Only listening sockets are considered for tcp.
+func procNetSocketLines(content, protocol string, port int) []string {
+	var matched []string
+	lines := strings.Split(content, "\n")
+	for _, line := range lines[1:] {
+		// e.g. "0: 00000000:2A03 00000000:0000 0A 00000000:00000000 00:00000000 00000000 0 0 12345 ..."
+		fields := strings.Fields(line)
+		if len(fields) < 10 {
+			continue
+		}
+		idx := strings.LastIndex(fields[1], ":")
+		if idx < 0 {
+			continue
+		}
+		localPort, err := strconv.ParseInt(fields[1][idx+1:], 16, 32)
+		if err != nil || int(localPort) != port {
+			continue
+		}
+		if protocol == "tcp" && fields[3] != tcpListenState {
+			continue
+		}
+		matched = append(matched, strings.TrimSpace(line))
+	}
+	return matched
+}
+
+// YurtHubHealthCheck probes the healthz endpoint of a YurtHub that may already be running on the node.
+// A healthy YurtHub means the node may already be onboarded, it's reported as info; a YurtHub that
+// listens but isn't healthy gets a warning; and a refused connection is expected for a fresh node.
+// The serving certificate is only validated when a CA is configured in TLS.
+type YurtHubHealthCheck struct {
+	Address string
+	TLS     TLSOptions
+}
+
+func (YurtHubHealthCheck) Name() string {
+	return "YurtHubHealth"
+}
+
+func (yhc YurtHubHealthCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"address": yhc.Address,
+		"caFile":  yhc.TLS.CAFile,
+	}
+}
+
+func (yhc YurtHubHealthCheck) Check() (warnings, errorList []error) {
+	url := fmt.Sprintf("https://%s%s", yhc.Address, constants.ServerHealthzURLPath)
+	klog.V(1).Infof("validating yurthub health %s", url)
+
+	client, err := newHTTPClient(yurtHubHealthTimeout, yhc.TLS, true)
+	if err != nil {
+		return []error{err}, nil
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			klog.V(1).Infof("no yurthub is listening on %s", yhc.Address)
+			return nil, nil
+		}
+		return []error{errors.Wrapf(err, "yurthub on %s is not healthy", yhc.Address)}, nil
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != "OK" {
+		return []error{errors.Errorf("yurthub on %s is not healthy, status code: %d, body: %s", yhc.Address, resp.StatusCode, body)}, nil
+	}
+	klog.Infof("yurthub on %s is healthy, the node may already be onboarded", yhc.Address)
+	return nil, nil
+}
+
+// VethCreationCheck verifies that a veth pair can be created and deleted, which CNI plugins
+// fundamentally rely on. It's skipped with a warning when not running as root.
+type VethCreationCheck struct{}
+
+func (VethCreationCheck) Name() string {
+	return "VethCreation"
+}
+
+func (VethCreationCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infoln("validating veth pair creation")
+
+	if os.Getuid() != 0 {
+		return []error{errors.New("not running as root, skipping veth creation check")}, nil
+	}
+
+	name, peer, err := newVethPairNames()
+	if err != nil {
+		return nil, []error{errors.Wrap(err, "failed to generate veth name")}
+	}
+	if err := createAndDeleteVeth(name, peer); err != nil {
+		return nil, []error{errors.Wrap(err, "node is not able to create veth pair, please make sure the kernel is built with CONFIG_VETH")}
+	}
+	return nil, nil
+}
+
+// newVethPairNames returns random names for a veth pair, short enough to fit in IFNAMSIZ.
+func newVethPairNames() (name, peer string, err error) {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", "", err
+	}
+	name = "pfveth" + hex.EncodeToString(suffix)
+	return name, name + "p", nil
+}
+
+// NetnsCreationCheck verifies that a network namespace can be created, which pod networking relies on
+// and some seccomp or LSM restricted environments block. A failure is only a warning when not running as root.
+type NetnsCreationCheck struct{}
+
+func (NetnsCreationCheck) Name() string {
+	return "NetnsCreation"
+}
+
+func (NetnsCreationCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infoln("validating network namespace creation")
+
+	if err := createAndDeleteNetns(); err != nil {
+		return netnsCreationFailure(err, os.Getuid() == 0)
+	}
+	return nil, nil
+}
+
+// netnsCreationFailure reports the failure to create a network namespace, which is only a warning
+// when not running as root, as it may be caused by the lack of privileges.
+func netnsCreationFailure(err error, root bool) (warnings, errorList []error) {
+	err = errors.Wrap(err, "node is not able to create network namespaces, please check the seccomp and LSM restrictions")
+	if !root {
+		return []error{errors.Wrap(err, "not running as root")}, nil
+	}
+	return nil, []error{err}
+}
+
+// defaultCNIInterfaces are the interface name patterns created by common CNI plugins.
+var defaultCNIInterfaces = []string{"cni0", "flannel.1", "cali*", "vxlan.calico", "tunl0", "weave", "cilium_*"}
+
+// StaleCNIInterfaceCheck warns about interfaces left by a previous CNI with addresses assigned,
+// which may come from a different pod CIDR and prevent the new CNI from initializing cleanly.
+type StaleCNIInterfaceCheck struct {
+	// KnownCNIIfaces are interface name patterns as accepted by filepath.Match, defaults to common CNI interfaces.
+	KnownCNIIfaces []string
+}
+
+func (StaleCNIInterfaceCheck) Name() string {
+	return "StaleCNIInterface"
+}
+
+func (scc StaleCNIInterfaceCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"knownCNIIfaces": scc.KnownCNIIfaces}
+}
+
+func (scc StaleCNIInterfaceCheck) Check() (warnings, errorList []error) {
+	patterns := scc.KnownCNIIfaces
+	if len(patterns) == 0 {
+		patterns = defaultCNIInterfaces
+	}
+	klog.V(1).Infof("validating no stale cni interface matching %v exists", patterns)
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return []error{errors.Wrap(err, "unable to list network interfaces")}, nil
+	}
+	for _, iface := range ifaces {
+		if !matchesAny(iface.Name, patterns) {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil || len(addrs) == 0 {
+			continue
+		}
+		warnings = append(warnings, errors.Errorf("interface %s left by a previous CNI has addresses %v, please delete it with 'ip link delete %s'", iface.Name, addrs, iface.Name))
+	}
+	return warnings, nil
+}
+
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// cloudMetadataEndpoints are the addresses of the metadata endpoints of the supported cloud providers.
+var cloudMetadataEndpoints = map[string]string{
+	"aws":       "169.254.169.254:80",
+	"azure":     "169.254.169.254:80",
+	"gce":       "169.254.169.254:80",
+	"openstack": "169.254.169.254:80",
+	"alibaba":   "100.100.100.200:80",
+}
+
+// CloudMetadataCheck verifies that the metadata endpoint of the cloud provider is reachable,
+// which the cloud controller manager relies on. It's skipped when Provider is empty.
+type CloudMetadataCheck struct {
+	Provider string
+	// Timeout defaults to 3s.
+	Timeout time.Duration
+}
+
+func (CloudMetadataCheck) Name() string {
+	return "CloudMetadata"
+}
+
+func (cmc CloudMetadataCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"provider": cmc.Provider,
+		"timeout":  cmc.Timeout.String(),
+	}
+}
+
+func (cmc CloudMetadataCheck) Check() (warnings, errorList []error) {
+	if cmc.Provider == "" {
+		return nil, nil
+	}
+	endpoint, ok := cloudMetadataEndpoints[strings.ToLower(cmc.Provider)]
+	if !ok {
+		return []error{errors.Errorf("unknown cloud provider %q, skipping metadata endpoint check", cmc.Provider)}, nil
+	}
+	timeout := cmc.Timeout
+	if timeout == 0 {
+		timeout = cloudMetadataTimeout
+	}
+	klog.V(1).Infof("validating reachability of %s metadata endpoint %s", cmc.Provider, endpoint)
+
+	conn, err := net.DialTimeout("tcp", endpoint, timeout)
+	if err != nil {
+		return []error{errors.Wrapf(err, "%s metadata endpoint %s is not reachable, the cloud controller manager may not work", cmc.Provider, endpoint)}, nil
+	}
+	conn.Close()
+	return nil, nil
+}
+
+// NetworkInterfaceCheck verifies that the node has at least one usable network interface,
+// i.e. an interface that is up, is not loopback and has a routable IP assigned.
+type NetworkInterfaceCheck struct{}
+
+func (NetworkInterfaceCheck) Name() string {
+	return "NetworkInterface"
+}
+
+func (NetworkInterfaceCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infoln("validating the node has a usable network interface")
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, []error{errors.Wrap(err, "unable to list network interfaces")}
+	}
+	var found, usable []string
+	for _, iface := range ifaces {
+		found = append(found, iface.Name)
+		if iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		if hasRoutableIP(addrs) {
+			usable = append(usable, iface.Name)
+		}
+	}
+	if len(usable) == 0 {
+		return nil, []error{errors.Errorf("no usable network interface with a routable IP found, interfaces on the node: %v", found)}
+	}
+	klog.V(1).Infof("usable network interfaces: %v", usable)
+	return nil, nil
+}
+
+// hasRoutableIP returns true if addrs contains an IP that is neither loopback, link-local nor unspecified.
+func hasRoutableIP(addrs []net.Addr) bool {
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if ok && ipNet.IP.IsGlobalUnicast() {
+			return true
+		}
+	}
+	return false
+}
+
+// CNIPluginBinariesCheck verifies that the CNI plugin binaries are present and executable in BinDir.
+// When Required is empty, the plugins are derived from the CNI config in effect in ConfDir,
+// which is the first one in lexical order as picked by the container runtime.
+type CNIPluginBinariesCheck struct {
+	// BinDir defaults to /opt/cni/bin.
+	BinDir   string
+	Required []string
+	// ConfDir defaults to /etc/cni/net.d.
+	ConfDir string
+}
+
+func (CNIPluginBinariesCheck) Name() string {
+	return "CNIPluginBinaries"
+}
+
+func (cpc CNIPluginBinariesCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"binDir":   cpc.BinDir,
+		"required": cpc.Required,
+		"confDir":  cpc.ConfDir,
+	}
+}
+
+func (cpc CNIPluginBinariesCheck) Check() (warnings, errorList []error) {
+	binDir := cpc.BinDir
+	if binDir == "" {
+		binDir = constants.KubeCniDir
+	}
+	required := cpc.Required
+	if len(required) == 0 {
+		confDir := cpc.ConfDir
+		if confDir == "" {
+			confDir = cniConfDir
+		}
+		plugins, err := cniConfPlugins(confDir)
+		if err != nil {
+			return []error{errors.Wrap(err, "unable to derive the required CNI plugins, skipping")}, nil
+		}
+		required = plugins
+	}
+	klog.V(1).Infof("validating cni plugin binaries %v in %s", required, binDir)
+
+	for _, plugin := range required {
+		path := filepath.Join(binDir, plugin)
+		info, err := os.Stat(path)
+		if err != nil {
+			errorList = append(errorList, errors.Errorf("cni plugin %s doesn't exist in %s", plugin, binDir))
+			continue
+		}
+		if !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+			errorList = append(errorList, errors.Errorf("cni plugin %s is not executable", path))
+		}
+	}
+	return nil, errorList
+}
+
+// cniConfPlugins returns the plugin types referenced by the first CNI config (.conf, .conflist or .json) in dir.
+func cniConfPlugins(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		switch filepath.Ext(entry.Name()) {
+		case ".conf", ".conflist", ".json":
+			if !entry.IsDir() {
+				files = append(files, entry.Name())
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.Errorf("no cni config found in %s", dir)
+	}
+	sort.Strings(files)
+
+	path := filepath.Join(dir, files[0])
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var conf struct {
+		Type    string `json:"type"`
+		Plugins []struct {
+			Type string `json:"type"`
+		} `json:"plugins"`
+	}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse cni config %s", path)
+	}
+
+	var plugins []string
+	if conf.Type != "" {
+		plugins = append(plugins, conf.Type)
+	}
+	for _, plugin := range conf.Plugins {
+		if plugin.Type != "" {
+			plugins = append(plugins, plugin.Type)
+		}
+	}
+	if len(plugins) == 0 {
+		return nil, errors.Errorf("no plugin found in cni config %s", path)
+	}
+	return plugins, nil
+}
+
+// RegistryMirrorCheck probes the /v2/ endpoint of every registry mirror and warns on the unreachable
+// ones, telling DNS, TCP and TLS failures apart. A 401 means the mirror is reachable but requires
+// authentication, which is fine. Mirrors without a scheme are probed over https.
+type RegistryMirrorCheck struct {
+	Mirrors []string
+	// Timeout defaults to 5s.
+	Timeout time.Duration
+	TLS     TLSOptions
+}
+
+func (RegistryMirrorCheck) Name() string {
+	return "RegistryMirror"
+}
+
+func (rmc RegistryMirrorCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"mirrors": rmc.Mirrors,
+		"timeout": rmc.Timeout.String(),
+		"caFile":  rmc.TLS.CAFile,
+	}
+}
+
+func (rmc RegistryMirrorCheck) Check() (warnings, errorList []error) {
+	timeout := rmc.Timeout
+	if timeout == 0 {
+		timeout = registryMirrorTimeout
+	}
+	client, err := newHTTPClient(timeout, rmc.TLS, false)
+	if err != nil {
+		return []error{err}, nil
+	}
+
+	for _, mirror := range rmc.Mirrors {
+		endpoint := strings.TrimSuffix(mirror, "/")
+		if !strings.Contains(endpoint, "://") {
+			endpoint = "https://" + endpoint
+		}
+		url := endpoint + "/v2/"
+		klog.V(1).Infof("validating reachability of registry mirror %s", url)
+
+		resp, err := client.Get(url)
+		if err != nil {
+			warnings = append(warnings, errors.Errorf("registry mirror %s is not reachable, %s: %v", mirror, classifyDialError(err), err))
+			continue
+		}
+		resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusOK:
+		case http.StatusUnauthorized:
+			klog.V(1).Infof("registry mirror %s is reachable but requires authentication", mirror)
+		default:
+			warnings = append(warnings, errors.Errorf("registry mirror %s responded to /v2/ with unexpected status code %d", mirror, resp.StatusCode))
+		}
+	}
+	return warnings, nil
+}
+
+// HTTPEndpointCheck sends a GET request to URL and warns when it fails or responds with a status
+// code other than ExpectStatus, e.g. for the mirrors the edge apps are pulled from during bootstrap.
+// The serving certificate is validated against the CA configured in TLS, or the system roots.
+type HTTPEndpointCheck struct {
+	URL string
+	// ExpectStatus defaults to 200.
+	ExpectStatus int
+	// Timeout defaults to 5s.
+	Timeout time.Duration
+	TLS     TLSOptions
+}
+
+func (HTTPEndpointCheck) Name() string {
+	return "HTTPEndpoint"
+}
+
+func (hec HTTPEndpointCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"url":          hec.URL,
+		"expectStatus": hec.ExpectStatus,
+		"timeout":      hec.Timeout.String(),
+		"caFile":       hec.TLS.CAFile,
+	}
+}
+
+func (hec HTTPEndpointCheck) Check() (warnings, errorList []error) {
+	expectStatus := hec.ExpectStatus
+	if expectStatus == 0 {
+		expectStatus = http.StatusOK
+	}
+	timeout := hec.Timeout
+	if timeout == 0 {
+		timeout = httpEndpointTimeout
+	}
+	klog.V(1).Infof("validating %s responds with status code %d", hec.URL, expectStatus)
+
+	client, err := newHTTPClient(timeout, hec.TLS, false)
+	if err != nil {
+		return []error{err}, nil
+	}
+	resp, err := client.Get(hec.URL)
+	if err != nil {
+		return []error{errors.Errorf("%s is not reachable, %s: %v", hec.URL, classifyDialError(err), err)}, nil
+	}
+	resp.Body.Close()
+	if resp.StatusCode != expectStatus {
+		return []error{errors.Errorf("%s responded with status code %d instead of %d", hec.URL, resp.StatusCode, expectStatus)}, nil
+	}
+	return nil, nil
+}
+
+// classifyDialError describes the stage at which a request failed.
+func classifyDialError(err error) string {
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var certErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	switch {
+	case errors.As(err, &dnsErr):
+		return "dns resolution failed"
+	case errors.As(err, &certErr), errors.As(err, &hostnameErr):
+		return "tls certificate is not trusted"
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return "tcp connection failed"
+	case os.IsTimeout(err):
+		return "request timed out"
+	default:
+		return "request failed"
+	}
+}
+
+// NATDetectionCheck sends a STUN binding request to StunServer and compares the reflexive address with
+// the local one. A source port that is not preserved is a characteristic of symmetric NAT, which may
+// break the reverse connectivity of the tunnel. It's skipped when StunServer is empty.
+type NATDetectionCheck struct {
+	// StunServer is the address of a STUN server, e.g. stun.example.com:3478.
+	StunServer string
+}
+
+func (NATDetectionCheck) Name() string {
+	return "NATDetection"
+}
+
+func (ndc NATDetectionCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"stunServer": ndc.StunServer}
+}
+
+func (ndc NATDetectionCheck) Check() (warnings, errorList []error) {
+	if ndc.StunServer == "" {
+		return nil, nil
+	}
+	klog.V(1).Infof("validating nat behavior against stun server %s", ndc.StunServer)
+
+	local, mapped, err := stunBinding(ndc.StunServer, stunTimeout)
+	if err != nil {
+		return []error{errors.Wrapf(err, "unable to detect nat behavior with stun server %s", ndc.StunServer)}, nil
+	}
+	if mapped.IP.Equal(local.IP) && mapped.Port == local.Port {
+		klog.V(1).Infof("node is not behind nat, address %s", mapped)
+		return nil, nil
+	}
+	if mapped.Port != local.Port {
+		return []error{errors.Errorf("node is behind a nat not preserving the source port (%s mapped to %s), which is a characteristic of symmetric nat and may break the tunnel", local, mapped)}, nil
+	}
+	klog.V(1).Infof("node is behind a nat preserving the source port, %s mapped to %s", local, mapped)
+	return nil, nil
+}
+
+// stunBinding sends a STUN binding request to server and returns the local address
+// and the reflexive address reported by the server.
+func stunBinding(server string, timeout time.Duration) (*net.UDPAddr, *net.UDPAddr, error) {
+	conn, err := net.DialTimeout("udp", server, timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+
+	txID := make([]byte, 12)
+	if _, err := rand.Read(txID); err != nil {
+		return nil, nil, err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write(newSTUNBindingRequest(txID)); err != nil {
+		return nil, nil, err
+	}
+	buf := make([]byte, 1500)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, nil, err
+	}
+	mapped, err := parseSTUNBindingResponse(buf[:n], txID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn.LocalAddr().(*net.UDPAddr), mapped, nil
+}
+
+func newSTUNBindingRequest(txID []byte) []byte {
+	msg := make([]byte, stunHeaderLength)
+	binary.BigEndian.PutUint16(msg[0:2], stunBindingRequest)
+	binary.BigEndian.PutUint16(msg[2:4], 0)
+	binary.BigEndian.PutUint32(msg[4:8], stunMagicCookie)
+	copy(msg[8:20], txID)
+	return msg
+}
+
+// parseSTUNBindingResponse returns the reflexive address in a STUN binding success response,
+// XOR-MAPPED-ADDRESS is preferred over MAPPED-ADDRESS.
+func parseSTUNBindingResponse(msg, txID []byte) (*net.UDPAddr, error) {
+	if len(msg) < stunHeaderLength {
+		return nil, errors.New("stun response is too short")
+	}
+	if binary.BigEndian.Uint16(msg[0:2]) != stunBindingSuccess {
+		return nil, errors.Errorf("unexpected stun message type 0x%04x", binary.BigEndian.Uint16(msg[0:2]))
+	}
+	if binary.BigEndian.Uint32(msg[4:8]) != stunMagicCookie || string(msg[8:20]) != string(txID) {
+		return nil, errors.New("stun response doesn't match the request")
+	}
+	length := int(binary.BigEndian.Uint16(msg[2:4]))
+	if stunHeaderLength+length > len(msg) {
+		return nil, errors.New("stun response is truncated")
+	}
+
+	var mapped *net.UDPAddr
+	attrs := msg[stunHeaderLength : stunHeaderLength+length]
+	for len(attrs) >= 4 {
+		attrType := binary.BigEndian.Uint16(attrs[0:2])
+		attrLen := int(binary.BigEndian.Uint16(attrs[2:4]))
+		if 4+attrLen > len(attrs) {
+			break
+		}
+		value := attrs[4 : 4+attrLen]
+		switch attrType {
+		case stunAttrXORMappedAddress:
+			if addr := parseSTUNAddress(value, msg[4:20]); addr != nil {
+				return addr, nil
+			}
+		case stunAttrMappedAddress:
+			mapped = parseSTUNAddress(value, nil)
+		}
+		// attributes are padded to a multiple of 4 bytes
+		attrs = attrs[4+(attrLen+3)&^3:]
+	}
+	if mapped == nil {
+		return nil, errors.New("no mapped address in stun response")
+	}
+	return mapped, nil
+}
+
+// parseSTUNAddress parses a (XOR-)MAPPED-ADDRESS attribute value, xor is the magic cookie
+// followed by the transaction id for XOR-MAPPED-ADDRESS, or nil for MAPPED-ADDRESS.
+func parseSTUNAddress(value, xor []byte) *net.UDPAddr {
+	if len(value) < 4 {
+		return nil
+	}
+	var ipLen int
+	switch value[1] {
+	case 0x01:
+		ipLen = net.IPv4len
+	case 0x02:
+		ipLen = net.IPv6len
+	default:
+		return nil
+	}
+	if len(value) < 4+ipLen {
+		return nil
+	}
+	port := binary.BigEndian.Uint16(value[2:4])
+	ip := make(net.IP, ipLen)
+	copy(ip, value[4:4+ipLen])
+	if xor != nil {
+		port ^= uint16(stunMagicCookie >> 16)
+		for i := range ip {
+			ip[i] ^= xor[i]
+		}
+	}
+	return &net.UDPAddr{IP: ip, Port: int(port)}
+}
+
+const (
+	// IPFamilyIPv4, IPFamilyIPv6 and IPFamilyDualStack are the cluster ip families accepted by IPFamilyCheck
+	IPFamilyIPv4      = "ipv4"
+	IPFamilyIPv6      = "ipv6"
+	IPFamilyDualStack = "dualstack"
+)
+
+// IPFamilyCheck verifies that the node has routable addresses of the ip families required by
+// the cluster, e.g. an IPv6 address for an IPv6 or dual-stack cluster.
+type IPFamilyCheck struct {
+	// ClusterFamily is one of ipv4, ipv6 and dualstack.
+	ClusterFamily string
+}
+
+func (IPFamilyCheck) Name() string {
+	return "IPFamily"
+}
+
+func (ifc IPFamilyCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"clusterFamily": ifc.ClusterFamily}
+}
+
+func (ifc IPFamilyCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infof("validating the node addresses match cluster ip family %s", ifc.ClusterFamily)
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, []error{errors.Wrap(err, "unable to list network interfaces")}
+	}
+	var addrs []net.Addr
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		ifaceAddrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		addrs = append(addrs, ifaceAddrs...)
+	}
+
+	if err := validateIPFamily(ifc.ClusterFamily, addrs); err != nil {
+		return nil, []error{err}
+	}
+	return nil, nil
+}
+
+// validateIPFamily returns an error if the routable addresses in addrs can't satisfy family.
+func validateIPFamily(family string, addrs []net.Addr) error {
+	var hasIPv4, hasIPv6 bool
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || !ipNet.IP.IsGlobalUnicast() {
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			hasIPv4 = true
+		} else {
+			hasIPv6 = true
+		}
+	}
+
+	var missing []string
+	switch strings.ToLower(family) {
+	case IPFamilyIPv4:
+		if !hasIPv4 {
+			missing = append(missing, "IPv4")
+		}
+	case IPFamilyIPv6:
+		if !hasIPv6 {
+			missing = append(missing, "IPv6")
+		}
+	case IPFamilyDualStack:
+		if !hasIPv4 {
+			missing = append(missing, "IPv4")
+		}
+		if !hasIPv6 {
+			missing = append(missing, "IPv6")
+		}
+	default:
+		return errors.Errorf("unknown cluster ip family %q, expected one of %s, %s and %s", family, IPFamilyIPv4, IPFamilyIPv6, IPFamilyDualStack)
+	}
+	if len(missing) != 0 {
+		return errors.Errorf("cluster ip family is %s, but the node has no routable %s address", family, strings.Join(missing, " or "))
+	}
+	return nil
+}
+
+const (
+	ipv4ForwardSysctl = "net.ipv4.ip_forward"
+	ipv6ForwardSysctl = "net.ipv6.conf.all.forwarding"
+)
+
+// IPForwardCheck verifies that forwarding is enabled for the ip families of the cluster,
+// net.ipv6.conf.all.forwarding is required besides net.ipv4.ip_forward by IPv6 and dual-stack clusters.
+type IPForwardCheck struct {
+	IPv4 bool
+	IPv6 bool
+}
+
+func (IPForwardCheck) Name() string {
+	return "IPForward"
+}
+
+func (ifc IPForwardCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"ipv4": ifc.IPv4,
+		"ipv6": ifc.IPv6,
+	}
+}
+
+func (ifc IPForwardCheck) Check() (warnings, errorList []error) {
+	sysctls := ipForwardSysctls(ifc.IPv4, ifc.IPv6)
+	klog.V(1).Infof("validating ip forwarding sysctls %v", sysctls)
+
+	for _, name := range sysctls {
+		value, err := readSysctl(name)
+		if err != nil {
+			errorList = append(errorList, errors.Wrapf(err, "unable to read sysctl %s", name))
+			continue
+		}
+		if value == "0" {
+			errorList = append(errorList, errors.Errorf("sysctl %s is set to 0, please set it to 1 to enable forwarding of pod traffic", name))
+		}
+	}
+	return nil, errorList
+}
+
+// ipForwardSysctls returns the forwarding sysctls required by the given ip families.
+func ipForwardSysctls(ipv4, ipv6 bool) []string {
+	var sysctls []string
+	if ipv4 {
+		sysctls = append(sysctls, ipv4ForwardSysctl)
+	}
+	if ipv6 {
+		sysctls = append(sysctls, ipv6ForwardSysctl)
+	}
+	return sysctls
+}
+
+// placeholderMACs are the MAC addresses known to be used as defaults by hypervisors and images,
+// which end up duplicated across cloned nodes.
+var placeholderMACs = []string{
+	"52:54:00:12:34:56", // default of qemu when no mac is given
+	"00:01:02:03:04:05",
+}
+
+// MACAddressCheck warns about non-loopback interfaces with a bogus MAC address, i.e. an all-zero,
+// multicast or well-known placeholder one, which confuses networking and node identity.
+type MACAddressCheck struct{}
+
+func (MACAddressCheck) Name() string {
+	return "MACAddress"
+}
+
+func (MACAddressCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infoln("validating mac addresses of network interfaces")
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return []error{errors.Wrap(err, "unable to list network interfaces")}, nil
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		if problem := invalidMACReason(iface.HardwareAddr); problem != "" {
+			warnings = append(warnings, errors.Errorf("interface %s has %s mac address %s", iface.Name, problem, iface.HardwareAddr))
+		}
+	}
+	return warnings, nil
+}
+
+// invalidMACReason returns why mac is not a usable unique address, or an empty string.
+// Only Ethernet addresses are validated, the link addresses of tunnel devices such as
+// tunl0 (4 bytes) and ip6tnl0 (16 bytes) are all-zero by design.
+func invalidMACReason(mac net.HardwareAddr) string {
+	if len(mac) != 6 {
+		return ""
+	}
+	allZero := true
+	for _, b := range mac {
+		if b != 0 {
+			allZero = false
+			break
+		}
+	}
+	switch {
+	case allZero:
+		return "an all-zero"
+	case mac[0]&0x01 != 0:
+		return "a multicast"
+	}
+	for _, placeholder := range placeholderMACs {
+		if strings.EqualFold(mac.String(), placeholder) {
+			return "a well-known placeholder"
+		}
+	}
+	return ""
+}
+
+// NFTablesSupportCheck verifies that nftables is usable on the node, which kube-proxy requires
+// in nftables mode. It's only meant to be added when the proxy mode is nftables.
+type NFTablesSupportCheck struct {
+	exec utilsexec.Interface
+}
+
+func (NFTablesSupportCheck) Name() string {
+	return "NFTablesSupport"
+}
+
+func (nsc NFTablesSupportCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infoln("validating nftables support")
+
+	out, err := nsc.exec.Command("nft", "--version").CombinedOutput()
+	if err != nil {
+		return nil, []error{errors.Wrapf(err, "nft is not available, it is required by kube-proxy in nftables mode, please install nftables, output: %s", strings.TrimSpace(string(out)))}
+	}
+	klog.V(1).Infof("nft version: %s", strings.TrimSpace(string(out)))
+
+	// listing the ruleset fails when the kernel lacks nf_tables support
+	if out, err := nsc.exec.Command("nft", "list", "ruleset").CombinedOutput(); err != nil {
+		return nil, []error{errors.Wrapf(err, "nftables is not supported by the kernel, it is required by kube-proxy in nftables mode, output: %s", strings.TrimSpace(string(out)))}
+	}
+	return nil, nil
+}
+
+const (
+	// reservedPodCIDRAddresses are the network, gateway and broadcast addresses of a per-node pod CIDR
+	reservedPodCIDRAddresses = 3
+	// defaultMaxPods is the default of --max-pods of kubelet
+	defaultMaxPods = 110
+)
+
+// PodCIDRCapacityCheck warns when the max pods of kubelet exceeds the usable addresses of the
+// per-node pod CIDR, as the pods beyond it fail to get an IP.
+type PodCIDRCapacityCheck struct {
+	NodeCIDRMaskSize int
+	// IPv6 is set when the per-node pod CIDR is an IPv6 one.
+	IPv6 bool
+	// MaxPods defaults to 110.
+	MaxPods int
+}
+
+func (PodCIDRCapacityCheck) Name() string {
+	return "PodCIDRCapacity"
+}
+
+func (pcc PodCIDRCapacityCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"nodeCIDRMaskSize": pcc.NodeCIDRMaskSize,
+		"ipv6":             pcc.IPv6,
+		"maxPods":          pcc.MaxPods,
+	}
+}
+
+func (pcc PodCIDRCapacityCheck) Check() (warnings, errorList []error) {
+	maxPods := pcc.MaxPods
+	if maxPods == 0 {
+		maxPods = defaultMaxPods
+	}
+	klog.V(1).Infof("validating /%d pod cidr can hold %d pods", pcc.NodeCIDRMaskSize, maxPods)
+
+	bits := 32
+	if pcc.IPv6 {
+		bits = 128
+	}
+	if pcc.NodeCIDRMaskSize <= 0 || pcc.NodeCIDRMaskSize > bits {
+		return []error{errors.Errorf("invalid node cidr mask size %d", pcc.NodeCIDRMaskSize)}, nil
+	}
+	usable := podCIDRUsableAddresses(pcc.NodeCIDRMaskSize, pcc.IPv6)
+	if maxPods > usable {
+		return []error{errors.Errorf("max pods %d exceeds the %d usable addresses of a /%d pod cidr, the pods beyond it will fail to get an ip", maxPods, usable, pcc.NodeCIDRMaskSize)}, nil
+	}
+	return nil, nil
+}
+
+// podCIDRUsableAddresses returns the number of addresses of a cidr with the mask size that can be assigned
+// to pods. IPv6 has no broadcast address, and the result is capped at math.MaxInt32 for large cidrs.
+func podCIDRUsableAddresses(maskSize int, ipv6 bool) int {
+	bits, reserved := 32, reservedPodCIDRAddresses
+	if ipv6 {
+		bits, reserved = 128, reservedPodCIDRAddresses-1
+	}
+	hostBits := bits - maskSize
+	if hostBits >= 31 {
+		return math.MaxInt32
+	}
+	usable := (1 << hostBits) - reserved
+	if usable < 0 {
+		return 0
+	}
+	return usable
+}
+
+// NSSwitchCheck warns when the hosts database in /etc/nsswitch.conf doesn't look up files before dns,
+// which makes the hostname of the node resolve through DNS and breaks local resolution in early boot.
+// It's skipped when /etc/nsswitch.conf doesn't exist, e.g. on musl based systems.
+type NSSwitchCheck struct{}
+
+func (NSSwitchCheck) Name() string {
+	return "NSSwitch"
+}
+
+func (NSSwitchCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infof("validating hosts database order in %s", nsswitchConf)
+
+	content, err := os.ReadFile(nsswitchConf)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return []error{errors.Wrapf(err, "unable to read %s", nsswitchConf)}, nil
+	}
+	if err := checkNSSwitchHosts(string(content)); err != nil {
+		return []error{err}, nil
+	}
+	return nil, nil
+}
+
+// checkNSSwitchHosts returns an error if the hosts line of nsswitch.conf lists dns but not files before it.
+func checkNSSwitchHosts(content string) error {
+	for _, line := range strings.Split(content, "\n") {
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "hosts:" {
+			continue
+		}
+
+		filesIdx, dnsIdx := -1, -1
+		for i, source := range fields[1:] {
+			switch source {
+			case "files":
+				if filesIdx < 0 {
+					filesIdx = i
+				}
+			case "dns":
+				if dnsIdx < 0 {
+					dnsIdx = i
+				}
+			}
+		}
+		if dnsIdx >= 0 && (filesIdx < 0 || filesIdx > dnsIdx) {
+			return errors.Errorf("hosts database in %s looks up dns before files: %q, please put files before dns", nsswitchConf, strings.TrimSpace(line))
+		}
+		return nil
+	}
+	return nil
+}
+
+// CarrierCheck verifies that the interface of the default route has carrier, an interface that is
+// administratively up but unplugged has no connectivity. Interface defaults to the one of the IPv4
+// default route with the lowest metric.
+type CarrierCheck struct {
+	Interface string
+}
+
+func (CarrierCheck) Name() string {
+	return "Carrier"
+}
+
+func (cc CarrierCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"interface": cc.Interface}
+}
+
+func (cc CarrierCheck) Check() (warnings, errorList []error) {
+	iface := cc.Interface
+	if iface == "" {
+		content, err := os.ReadFile(procNetRoute)
+		if err != nil {
+			return []error{errors.Wrapf(err, "unable to read %s", procNetRoute)}, nil
+		}
+		if iface, err = parseDefaultRouteInterface(string(content)); err != nil {
+			return []error{err}, nil
+		}
+	}
+	klog.V(1).Infof("validating carrier of interface %s", iface)
+
+	path := filepath.Join(sysClassNet, iface, "carrier")
+	content, err := os.ReadFile(path)
+	if err != nil {
+		// reading carrier of an interface that is down fails with EINVAL
+		if errors.Is(err, syscall.EINVAL) {
+			return nil, []error{errors.Errorf("interface %s is down", iface)}
+		}
+		return []error{errors.Wrapf(err, "unable to read %s", path)}, nil
+	}
+	if strings.TrimSpace(string(content)) != "1" {
+		return nil, []error{errors.Errorf("interface %s has no carrier, please check the cable or the link of the NIC", iface)}
+	}
+	return nil, nil
+}
+
+// parseDefaultRouteInterface returns the interface of the default route with the lowest metric in /proc/net/route.
+func parseDefaultRouteInterface(content string) (string, error) {
+	iface, lowest := "", -1
+	for i, line := range strings.Split(content, "\n") {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask MTU Window IRTT
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) < 8 || fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		metric, err := strconv.Atoi(fields[6])
+		if err != nil {
+			continue
+		}
+		if lowest < 0 || metric < lowest {
+			iface, lowest = fields[0], metric
+		}
+	}
+	if iface == "" {
+		return "", errors.Errorf("no default route is found in %s", procNetRoute)
+	}
+	return iface, nil
+}
+
+// BridgeGatewayConflictCheck warns when the intended gateway ip of the pod bridge is already in use
+// on the host network, i.e. assigned to an interface or resolved in the ARP table, which misroutes pod traffic.
+type BridgeGatewayConflictCheck struct {
+	GatewayIP string
+}
+
+func (BridgeGatewayConflictCheck) Name() string {
+	return "BridgeGatewayConflict"
+}
+
+func (bgc BridgeGatewayConflictCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"gatewayIP": bgc.GatewayIP}
+}
+
+func (bgc BridgeGatewayConflictCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infof("validating bridge gateway ip %s is not in use", bgc.GatewayIP)
+
+	gateway := net.ParseIP(bgc.GatewayIP)
+	if gateway == nil {
+		return nil, []error{errors.Errorf("invalid bridge gateway ip %q", bgc.GatewayIP)}
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return []error{errors.Wrap(err, "unable to list network interfaces")}, nil
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(gateway) {
+				warnings = append(warnings, errors.Errorf("bridge gateway ip %s is already assigned to interface %s", gateway, iface.Name))
+			}
+		}
+	}
+
+	content, err := os.ReadFile(procNetARP)
+	if err != nil {
+		return append(warnings, errors.Wrapf(err, "unable to read %s", procNetARP)), nil
+	}
+	if device := arpEntryDevice(string(content), gateway); device != "" {
+		warnings = append(warnings, errors.Errorf("bridge gateway ip %s is already used by another host reachable via interface %s", gateway, device))
+	}
+	return warnings, nil
+}
+
+// arpEntryDevice returns the device of the resolved entry of ip in /proc/net/arp, or an empty string if there is none.
+func arpEntryDevice(content string, ip net.IP) string {
+	for i, line := range strings.Split(content, "\n") {
+		// IP address HW type Flags HW address Mask Device
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) < 6 {
+			continue
+		}
+		// flags 0x0 marks an incomplete entry, i.e. nobody answered for the ip
+		if fields[2] == "0x0" || fields[3] == "00:00:00:00:00:00" {
+			continue
+		}
+		if entryIP := net.ParseIP(fields[0]); entryIP != nil && entryIP.Equal(ip) {
+			return fields[5]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,722 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"crypto/x509"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/openyurtio/openyurt/pkg/yurtadm/constants"
+
+	utilsexec "k8s.io/utils/exec"
+	fakeexec "k8s.io/utils/exec/testing"
+)
+
+func TestParseProcNetInodes(t *testing.T) {
+	content := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:281A 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 23456 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:281A 0100007F:9C40 01 00000000:00000000 00:00000000 00000000     0        0 34567 1 0000000000000000 20 4 30 10 -1
+   2: 0100007F:2819 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 45678 1 0000000000000000 100 0 0 10 0
+`
+	tests := []struct {
+		name     string
+		protocol string
+		port     int
+		expected []string
+	}{
+		{
+			name:     "tcp only matches listening sockets",
+			protocol: "tcp",
+			port:     10266,
+			expected: []string{"23456"},
+		},
+		{
+			name:     "udp matches all sockets",
+			protocol: "udp",
+			port:     10266,
+			expected: []string{"23456", "34567"},
+		},
+		{
+			name:     "port not bound",
+			protocol: "tcp",
+			port:     10250,
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inodes := parseProcNetInodes(content, tt.protocol, tt.port)
+			if !reflect.DeepEqual(inodes, tt.expected) {
+				t.Errorf("expected inodes %v, got %v", tt.expected, inodes)
+			}
+		})
+	}
+}
+
+func TestPortRangeCheck(t *testing.T) {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	_, errs := PortRangeCheck{Ports: []PortSpec{{Port: port, Label: "test"}}}.Check()
+	if len(errs) != 1 {
+		t.Errorf("expected port %d reported in use, got %v", port, errs)
+	}
+}
+
+func mustParseAddrs(t *testing.T, cidrs ...string) []net.Addr {
+	var addrs []net.Addr
+	for _, cidr := range cidrs {
+		ip, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", cidr, err)
+		}
+		ipNet.IP = ip
+		addrs = append(addrs, ipNet)
+	}
+	return addrs
+}
+
+func TestHasIPNet(t *testing.T) {
+	tests := []struct {
+		name     string
+		addrs    []string
+		cidr     string
+		expected bool
+	}{
+		{name: "no address", cidr: "127.0.0.1/8", expected: false},
+		{name: "ipv4 assigned", addrs: []string{"127.0.0.1/8", "::1/128"}, cidr: "127.0.0.1/8", expected: true},
+		{name: "ipv6 assigned", addrs: []string{"127.0.0.1/8", "::1/128"}, cidr: "::1/128", expected: true},
+		{name: "ipv6 missing", addrs: []string{"127.0.0.1/8"}, cidr: "::1/128", expected: false},
+		{name: "different prefix length", addrs: []string{"127.0.0.1/32"}, cidr: "127.0.0.1/8", expected: false},
+	}
+	for _, tt := range tests {
+		if got := hasIPNet(mustParseAddrs(t, tt.addrs...), tt.cidr); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestValidateAdvertiseAddress(t *testing.T) {
+	addrs := mustParseAddrs(t, "127.0.0.1/8", "192.168.1.10/24", "2001:db8::10/64")
+
+	tests := []struct {
+		name      string
+		address   string
+		expectErr bool
+	}{
+		{name: "assigned ipv4", address: "192.168.1.10", expectErr: false},
+		{name: "assigned ipv6", address: "2001:db8::10", expectErr: false},
+		{name: "not assigned", address: "192.168.1.11", expectErr: true},
+		{name: "invalid", address: "edge-1", expectErr: true},
+		{name: "unspecified", address: "0.0.0.0", expectErr: true},
+		{name: "loopback", address: "127.0.0.1", expectErr: true},
+		{name: "link-local", address: "169.254.10.1", expectErr: true},
+	}
+	for _, tt := range tests {
+		if err := validateAdvertiseAddress(tt.address, addrs); (err != nil) != tt.expectErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.expectErr, err)
+		}
+	}
+}
+
+func TestYurtHubHealthCheck(t *testing.T) {
+	handler := func(status int, body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != constants.ServerHealthzURLPath {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+		})
+	}
+	healthy := httptest.NewTLSServer(handler(http.StatusOK, "OK"))
+	defer healthy.Close()
+	unhealthy := httptest.NewTLSServer(handler(http.StatusInternalServerError, "cache not ready"))
+	defer unhealthy.Close()
+	closed := httptest.NewTLSServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name           string
+		server         *httptest.Server
+		expectWarnings int
+	}{
+		{name: "healthy", server: healthy},
+		{name: "unhealthy", server: unhealthy, expectWarnings: 1},
+		{name: "not running", server: closed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address := strings.TrimPrefix(tt.server.URL, "https://")
+			warnings, errs := YurtHubHealthCheck{Address: address}.Check()
+			if len(warnings) != tt.expectWarnings || len(errs) != 0 {
+				t.Errorf("expected %d warnings and no errors, got warnings %v, errors %v", tt.expectWarnings, warnings, errs)
+			}
+		})
+	}
+}
+
+func TestNewVethPairNames(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 10; i++ {
+		name, peer, err := newVethPairNames()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, ifName := range []string{name, peer} {
+			// IFNAMSIZ is 16 including the terminating NUL
+			if len(ifName) > 15 {
+				t.Errorf("interface name %s is longer than 15 characters", ifName)
+			}
+			if seen[ifName] {
+				t.Errorf("interface name %s is generated twice", ifName)
+			}
+			seen[ifName] = true
+		}
+	}
+}
+
+func TestNetnsCreationFailure(t *testing.T) {
+	tests := []struct {
+		name           string
+		root           bool
+		expectWarnings int
+		expectErrors   int
+	}{
+		{name: "root", root: true, expectErrors: 1},
+		{name: "not root", root: false, expectWarnings: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, errs := netnsCreationFailure(syscall.EPERM, tt.root)
+			if len(warnings) != tt.expectWarnings || len(errs) != tt.expectErrors {
+				t.Errorf("expected %d warnings and %d errors, got warnings %v, errors %v", tt.expectWarnings, tt.expectErrors, warnings, errs)
+			}
+		})
+	}
+}
+
+func TestMatchesAny(t *testing.T) {
+	tests := []struct {
+		name     string
+		iface    string
+		patterns []string
+		expected bool
+	}{
+		{name: "exact name", iface: "cni0", patterns: defaultCNIInterfaces, expected: true},
+		{name: "wildcard", iface: "cali1a2b3c4d5e6", patterns: defaultCNIInterfaces, expected: true},
+		{name: "dot is not a wildcard", iface: "flannelx1", patterns: defaultCNIInterfaces, expected: false},
+		{name: "host interface", iface: "eth0", patterns: defaultCNIInterfaces, expected: false},
+		{name: "no patterns", iface: "cni0", expected: false},
+	}
+	for _, tt := range tests {
+		if got := matchesAny(tt.iface, tt.patterns); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestCloudMetadataCheck(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+	defer ln.Close()
+
+	cloudMetadataEndpoints["reachable"] = ln.Addr().String()
+	cloudMetadataEndpoints["unreachable"] = closedAddr
+	defer func() {
+		delete(cloudMetadataEndpoints, "reachable")
+		delete(cloudMetadataEndpoints, "unreachable")
+	}()
+
+	tests := []struct {
+		provider       string
+		expectWarnings int
+	}{
+		{provider: ""},
+		{provider: "reachable"},
+		{provider: "unreachable", expectWarnings: 1},
+		{provider: "unknown", expectWarnings: 1},
+	}
+	for _, tt := range tests {
+		warnings, errs := CloudMetadataCheck{Provider: tt.provider, Timeout: time.Second}.Check()
+		if len(warnings) != tt.expectWarnings || len(errs) != 0 {
+			t.Errorf("provider %q: expected %d warnings and no errors, got warnings %v, errors %v", tt.provider, tt.expectWarnings, warnings, errs)
+		}
+	}
+}
+
+func TestHasRoutableIP(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		addrs    []string
+		expected bool
+	}{
+		{name: "no address", expected: false},
+		{name: "link-local only", addrs: []string{"169.254.10.1/16", "fe80::1/64"}, expected: false},
+		{name: "loopback only", addrs: []string{"127.0.0.1/8", "::1/128"}, expected: false},
+		{name: "private ipv4", addrs: []string{"fe80::1/64", "192.168.1.10/24"}, expected: true},
+		{name: "global ipv6", addrs: []string{"2001:db8::10/64"}, expected: true},
+	}
+	for _, tt := range tests {
+		if got := hasRoutableIP(mustParseAddrs(t, tt.addrs...)); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestCNIPluginBinariesCheck(t *testing.T) {
+	binDir := t.TempDir()
+	for name, mode := range map[string]os.FileMode{"bridge": 0755, "portmap": 0755, "loopback": 0644} {
+		if err := os.WriteFile(filepath.Join(binDir, name), []byte("test"), mode); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	confDir := t.TempDir()
+	conflist := `{"cniVersion": "0.4.0", "name": "cbr0", "plugins": [{"type": "flannel"}, {"type": "portmap"}]}`
+	conf := `{"cniVersion": "0.4.0", "name": "bridge", "type": "bridge"}`
+	if err := os.WriteFile(filepath.Join(confDir, "10-flannel.conflist"), []byte(conflist), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(confDir, "20-bridge.conf"), []byte(conf), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		check          CNIPluginBinariesCheck
+		expectWarnings int
+		expectErrors   int
+	}{
+		{
+			name:  "required plugins present",
+			check: CNIPluginBinariesCheck{BinDir: binDir, Required: []string{"bridge", "portmap"}},
+		},
+		{
+			name:         "required plugins missing or not executable",
+			check:        CNIPluginBinariesCheck{BinDir: binDir, Required: []string{"bridge", "host-local", "loopback"}},
+			expectErrors: 2,
+		},
+		{
+			name:         "plugins derived from the first cni config",
+			check:        CNIPluginBinariesCheck{BinDir: binDir, ConfDir: confDir},
+			expectErrors: 1,
+		},
+		{
+			name:           "no cni config",
+			check:          CNIPluginBinariesCheck{BinDir: binDir, ConfDir: t.TempDir()},
+			expectWarnings: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, errs := tt.check.Check()
+			if len(warnings) != tt.expectWarnings || len(errs) != tt.expectErrors {
+				t.Errorf("expected %d warnings and %d errors, got warnings %v, errors %v", tt.expectWarnings, tt.expectErrors, warnings, errs)
+			}
+		})
+	}
+}
+
+func TestRegistryMirrorCheck(t *testing.T) {
+	handler := func(status int) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v2/" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(status)
+		})
+	}
+	ok := httptest.NewServer(handler(http.StatusOK))
+	defer ok.Close()
+	auth := httptest.NewServer(handler(http.StatusUnauthorized))
+	defer auth.Close()
+	broken := httptest.NewServer(handler(http.StatusInternalServerError))
+	defer broken.Close()
+	closed := httptest.NewServer(handler(http.StatusOK))
+	closed.Close()
+
+	tests := []struct {
+		name           string
+		mirrors        []string
+		expectWarnings int
+	}{
+		{name: "reachable", mirrors: []string{ok.URL + "/"}},
+		{name: "reachable but requires authentication", mirrors: []string{auth.URL}},
+		{name: "unexpected status code", mirrors: []string{broken.URL}, expectWarnings: 1},
+		{name: "connection refused", mirrors: []string{closed.URL, ok.URL}, expectWarnings: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, errs := RegistryMirrorCheck{Mirrors: tt.mirrors, Timeout: time.Second}.Check()
+			if len(warnings) != tt.expectWarnings || len(errs) != 0 {
+				t.Errorf("expected %d warnings and no errors, got warnings %v, errors %v", tt.expectWarnings, warnings, errs)
+			}
+		})
+	}
+}
+
+func TestHTTPEndpointCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/manifests" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	private := httptest.NewTLSServer(http.NotFoundHandler())
+	defer private.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(private.Certificate())
+
+	tests := []struct {
+		name           string
+		check          HTTPEndpointCheck
+		expectWarnings int
+	}{
+		{name: "expected status code", check: HTTPEndpointCheck{URL: server.URL + "/manifests"}},
+		{name: "custom expected status code", check: HTTPEndpointCheck{URL: server.URL, ExpectStatus: http.StatusNotFound}},
+		{name: "unexpected status code", check: HTTPEndpointCheck{URL: server.URL}, expectWarnings: 1},
+		{name: "connection refused", check: HTTPEndpointCheck{URL: closed.URL}, expectWarnings: 1},
+		{name: "private CA", check: HTTPEndpointCheck{URL: private.URL, ExpectStatus: http.StatusNotFound, TLS: TLSOptions{CertPool: pool}}},
+		{name: "untrusted certificate", check: HTTPEndpointCheck{URL: private.URL, ExpectStatus: http.StatusNotFound}, expectWarnings: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.check.Timeout = time.Second
+			warnings, errs := tt.check.Check()
+			if len(warnings) != tt.expectWarnings || len(errs) != 0 {
+				t.Errorf("expected %d warnings and no errors, got warnings %v, errors %v", tt.expectWarnings, warnings, errs)
+			}
+		})
+	}
+}
+
+// newFakeSTUNServer answers binding requests with the source address of the request,
+// shifted by portShift to simulate a nat not preserving the source port.
+func newFakeSTUNServer(t *testing.T, portShift int) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < stunHeaderLength {
+				continue
+			}
+			src := addr.(*net.UDPAddr)
+			value := make([]byte, 8)
+			value[1] = 0x01
+			binary.BigEndian.PutUint16(value[2:4], uint16(src.Port+portShift)^uint16(stunMagicCookie>>16))
+			for i, b := range src.IP.To4() {
+				value[4+i] = b ^ buf[4+i]
+			}
+			resp := make([]byte, stunHeaderLength+4, stunHeaderLength+12)
+			binary.BigEndian.PutUint16(resp[0:2], stunBindingSuccess)
+			binary.BigEndian.PutUint16(resp[2:4], 12)
+			copy(resp[4:20], buf[4:20])
+			binary.BigEndian.PutUint16(resp[20:22], stunAttrXORMappedAddress)
+			binary.BigEndian.PutUint16(resp[22:24], 8)
+			resp = append(resp, value...)
+			conn.WriteTo(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestNATDetectionCheck(t *testing.T) {
+	tests := []struct {
+		name           string
+		server         string
+		expectWarnings int
+	}{
+		{name: "no stun server"},
+		{name: "no nat", server: newFakeSTUNServer(t, 0)},
+		{name: "source port not preserved", server: newFakeSTUNServer(t, 1), expectWarnings: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, errs := NATDetectionCheck{StunServer: tt.server}.Check()
+			if len(warnings) != tt.expectWarnings || len(errs) != 0 {
+				t.Errorf("expected %d warnings and no errors, got warnings %v, errors %v", tt.expectWarnings, warnings, errs)
+			}
+		})
+	}
+}
+
+func TestParseSTUNBindingResponse(t *testing.T) {
+	txID := []byte("0123456789ab")
+	resp := []byte{
+		0x01, 0x01, 0x00, 0x0c, 0x21, 0x12, 0xa4, 0x42,
+	}
+	resp = append(resp, txID...)
+	// MAPPED-ADDRESS 192.0.2.1:32853
+	resp = append(resp, 0x00, 0x01, 0x00, 0x08, 0x00, 0x01, 0x80, 0x55, 192, 0, 2, 1)
+
+	addr, err := parseSTUNBindingResponse(resp, txID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if addr.String() != "192.0.2.1:32853" {
+		t.Errorf("expected address 192.0.2.1:32853, got %s", addr)
+	}
+	if _, err := parseSTUNBindingResponse(resp, []byte("ba9876543210")); err == nil {
+		t.Errorf("expected error for mismatched transaction id")
+	}
+}
+
+func TestValidateIPFamily(t *testing.T) {
+	ipv4 := []string{"192.168.1.10/24", "fe80::1/64"}
+	dualStack := []string{"192.168.1.10/24", "2001:db8::10/64"}
+	tests := []struct {
+		family    string
+		addrs     []string
+		expectErr bool
+	}{
+		{family: IPFamilyIPv4, addrs: ipv4},
+		{family: IPFamilyIPv6, addrs: ipv4, expectErr: true},
+		{family: IPFamilyDualStack, addrs: ipv4, expectErr: true},
+		{family: IPFamilyDualStack, addrs: dualStack},
+		{family: IPFamilyIPv6, addrs: dualStack},
+		{family: "ipv5", addrs: dualStack, expectErr: true},
+	}
+	for _, tt := range tests {
+		if err := validateIPFamily(tt.family, mustParseAddrs(t, tt.addrs...)); (err != nil) != tt.expectErr {
+			t.Errorf("family %s with addrs %v: expected error %v, got %v", tt.family, tt.addrs, tt.expectErr, err)
+		}
+	}
+}
+
+func TestIPForwardSysctls(t *testing.T) {
+	tests := []struct {
+		ipv4, ipv6 bool
+		expected   []string
+	}{
+		{ipv4: true, expected: []string{ipv4ForwardSysctl}},
+		{ipv6: true, expected: []string{ipv6ForwardSysctl}},
+		{ipv4: true, ipv6: true, expected: []string{ipv4ForwardSysctl, ipv6ForwardSysctl}},
+		{},
+	}
+	for _, tt := range tests {
+		if sysctls := ipForwardSysctls(tt.ipv4, tt.ipv6); !reflect.DeepEqual(sysctls, tt.expected) {
+			t.Errorf("ipv4 %v, ipv6 %v: expected %v, got %v", tt.ipv4, tt.ipv6, tt.expected, sysctls)
+		}
+	}
+}
+
+func TestInvalidMACReason(t *testing.T) {
+	tests := []struct {
+		mac     string
+		invalid bool
+	}{
+		{mac: "00:00:00:00:00:00", invalid: true},
+		{mac: "01:00:5e:00:00:01", invalid: true},
+		{mac: "ff:ff:ff:ff:ff:ff", invalid: true},
+		{mac: "52:54:00:12:34:56", invalid: true},
+		{mac: "52:54:00:ab:cd:ef", invalid: false},
+		{mac: "fa:16:3e:11:22:33", invalid: false},
+	}
+	for _, tt := range tests {
+		mac, err := net.ParseMAC(tt.mac)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", tt.mac, err)
+		}
+		if reason := invalidMACReason(mac); (reason != "") != tt.invalid {
+			t.Errorf("mac %s: expected invalid %v, got %q", tt.mac, tt.invalid, reason)
+		}
+	}
+
+	// tunl0, sit0 and ip6tnl0 have all-zero link addresses which are not Ethernet addresses
+	for _, mac := range []net.HardwareAddr{nil, make(net.HardwareAddr, 4), make(net.HardwareAddr, 16)} {
+		if reason := invalidMACReason(mac); reason != "" {
+			t.Errorf("link address %v: expected to be skipped, got %q", []byte(mac), reason)
+		}
+	}
+}
+
+func TestNFTablesSupportCheck(t *testing.T) {
+	succeed := func() ([]byte, []byte, error) { return []byte("nftables v1.0.2 (Lester Gooch)"), nil, nil }
+	fail := func() ([]byte, []byte, error) {
+		return []byte("Error: Could not process rule: Operation not supported"), nil, &fakeexec.FakeExitError{Status: 1}
+	}
+
+	tests := []struct {
+		name         string
+		actions      []fakeexec.FakeAction
+		expectErrors int
+	}{
+		{name: "nftables supported", actions: []fakeexec.FakeAction{succeed, succeed}},
+		{name: "nft missing", actions: []fakeexec.FakeAction{fail}, expectErrors: 1},
+		{name: "kernel lacks nf_tables", actions: []fakeexec.FakeAction{succeed, fail}, expectErrors: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fcmd := fakeexec.FakeCmd{CombinedOutputScript: tt.actions}
+			fexec := &fakeexec.FakeExec{}
+			for range tt.actions {
+				fexec.CommandScript = append(fexec.CommandScript, func(cmd string, args ...string) utilsexec.Cmd {
+					return fakeexec.InitFakeCmd(&fcmd, cmd, args...)
+				})
+			}
+
+			_, errs := NFTablesSupportCheck{exec: fexec}.Check()
+			if len(errs) != tt.expectErrors {
+				t.Errorf("expected %d errors, got %v", tt.expectErrors, errs)
+			}
+		})
+	}
+}
+
+func TestPodCIDRCapacityCheck(t *testing.T) {
+	tests := []struct {
+		maskSize       int
+		ipv6           bool
+		maxPods        int
+		expectWarnings int
+	}{
+		{maskSize: 24},
+		{maskSize: 24, maxPods: 253},
+		{maskSize: 24, maxPods: 254, expectWarnings: 1},
+		{maskSize: 26, expectWarnings: 1},
+		{maskSize: 31, maxPods: 1, expectWarnings: 1},
+		{maskSize: 0, expectWarnings: 1},
+		{maskSize: 33, expectWarnings: 1},
+		{maskSize: 64, ipv6: true},
+		{maskSize: 1, ipv6: true},
+		{maskSize: 122, ipv6: true, expectWarnings: 1},
+		{maskSize: 120, ipv6: true, maxPods: 254},
+		{maskSize: 129, ipv6: true, expectWarnings: 1},
+	}
+	for _, tt := range tests {
+		warnings, errs := PodCIDRCapacityCheck{NodeCIDRMaskSize: tt.maskSize, IPv6: tt.ipv6, MaxPods: tt.maxPods}.Check()
+		if len(warnings) != tt.expectWarnings || len(errs) != 0 {
+			t.Errorf("/%d with max pods %d: expected %d warnings and no errors, got warnings %v, errors %v", tt.maskSize, tt.maxPods, tt.expectWarnings, warnings, errs)
+		}
+	}
+}
+
+func TestCheckNSSwitchHosts(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		expectErr bool
+	}{
+		{name: "files before dns", content: "passwd: files\nhosts: files mdns4_minimal [NOTFOUND=return] dns myhostname\n"},
+		{name: "dns before files", content: "hosts: dns files\n", expectErr: true},
+		{name: "no files", content: "hosts: dns\n", expectErr: true},
+		{name: "no dns", content: "hosts: files myhostname\n"},
+		{name: "commented out", content: "# hosts: dns files\nhosts: files dns\n"},
+		{name: "no hosts line", content: "passwd: files\n"},
+	}
+	for _, tt := range tests {
+		if err := checkNSSwitchHosts(tt.content); (err != nil) != tt.expectErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.expectErr, err)
+		}
+	}
+}
+
+func TestParseDefaultRouteInterface(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		expected  string
+		expectErr bool
+	}{
+		{
+			name: "lowest metric",
+			content: `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+wlan0	00000000	0101A8C0	0003	0	0	600	00000000	0	0	0
+eth0	00000000	0100000A	0003	0	0	100	00000000	0	0	0
+eth0	0000000A	00000000	0001	0	0	100	00FFFFFF	0	0	0
+`,
+			expected: "eth0",
+		},
+		{
+			name: "no default route",
+			content: `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	0000000A	00000000	0001	0	0	100	00FFFFFF	0	0	0
+`,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iface, err := parseDefaultRouteInterface(tt.content)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("expected error %v, got %v", tt.expectErr, err)
+			}
+			if iface != tt.expected {
+				t.Errorf("expected interface %q, got %q", tt.expected, iface)
+			}
+		})
+	}
+}
+
+func TestARPEntryDevice(t *testing.T) {
+	content := `IP address       HW type     Flags       HW address            Mask     Device
+192.168.1.1      0x1         0x2         52:54:00:aa:bb:cc     *        eth0
+10.244.0.1       0x1         0x0         00:00:00:00:00:00     *        eth0
+`
+	tests := []struct {
+		ip       string
+		expected string
+	}{
+		{ip: "192.168.1.1", expected: "eth0"},
+		{ip: "10.244.0.1"},
+		{ip: "10.244.1.1"},
+	}
+	for _, tt := range tests {
+		if device := arpEntryDevice(content, net.ParseIP(tt.ip)); device != tt.expected {
+			t.Errorf("ip %s: expected device %q, got %q", tt.ip, tt.expected, device)
+		}
+	}
+}
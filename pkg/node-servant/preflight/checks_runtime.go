@@ -0,0 +1,568 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/klog/v2"
+
+	"github.com/openyurtio/openyurt/pkg/node-servant/components"
+)
+
+const (
+	defaultRuntimeHandler = "runc"
+
+	containerdConfigPath   = "/etc/containerd/config.toml"
+	dockerDaemonConfigPath = "/etc/docker/daemon.json"
+
+	// defaultMinCRIAPIVersion is the oldest CRI API version kubelet is able to talk to
+	defaultMinCRIAPIVersion = "v1alpha2"
+
+	criSocketDialTimeout = 3 * time.Second
+
+	// dockershimRemovedVersion is the Kubernetes version in which dockershim was removed from kubelet
+	dockershimRemovedVersion = "v1.24.0"
+)
+
+// RuntimeHandlerCheck verifies that the container runtime has a handler configured
+// for every RuntimeClass the workloads on this node require (e.g. runc, kata, nvidia).
+type RuntimeHandlerCheck struct {
+	runtime  components.ContainerRuntimeForImage
+	Handlers []string
+}
+
+func (RuntimeHandlerCheck) Name() string {
+	return "RuntimeHandler"
+}
+
+func (RuntimeHandlerCheck) DependsOn() []string {
+	return []string{"CRIStatus"}
+}
+
+func (rhc RuntimeHandlerCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"handlers": rhc.Handlers}
+}
+
+func (rhc RuntimeHandlerCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infof("validating container runtime handlers %v", rhc.Handlers)
+
+	handlers, err := rhc.runtime.ListRuntimeHandlers()
+	if err != nil {
+		return nil, []error{errors.Wrap(err, "failed to list container runtime handlers")}
+	}
+
+	configured := sets.NewString(handlers...)
+	for _, handler := range rhc.Handlers {
+		if !configured.Has(handler) {
+			errorList = append(errorList, errors.Errorf("runtime handler %q is not configured in the container runtime, configured handlers: %v", handler, configured.List()))
+		}
+	}
+	return nil, errorList
+}
+
+// DefaultRuntimeHandlerCheck verifies that the default runtime handler of the container runtime
+// is the expected one, a misconfigured default handler breaks all pod starts.
+type DefaultRuntimeHandlerCheck struct {
+	runtime components.ContainerRuntimeForImage
+	// Expected is the expected default runtime handler, defaults to runc.
+	Expected string
+}
+
+func (DefaultRuntimeHandlerCheck) Name() string {
+	return "DefaultRuntimeHandler"
+}
+
+func (DefaultRuntimeHandlerCheck) DependsOn() []string {
+	return []string{"CRIStatus"}
+}
+
+func (drc DefaultRuntimeHandlerCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"expected": drc.Expected}
+}
+
+func (drc DefaultRuntimeHandlerCheck) Check() (warnings, errorList []error) {
+	expected := drc.Expected
+	if expected == "" {
+		expected = defaultRuntimeHandler
+	}
+	klog.V(1).Infof("validating default runtime handler is %s", expected)
+
+	handler, err := drc.runtime.DefaultRuntimeHandler()
+	if err != nil {
+		return nil, []error{errors.Wrap(err, "failed to get default runtime handler")}
+	}
+	if handler != expected {
+		return nil, []error{errors.Errorf("default runtime handler of the container runtime is %q instead of %q", handler, expected)}
+	}
+	return nil, nil
+}
+
+// CgroupDriverCheck verifies that the container runtime uses the same cgroup driver as kubelet.
+// The driver reported by the runtime (CRI RuntimeConfig, or docker info) is preferred, and the
+// runtime config file is parsed instead when the runtime doesn't report it.
+type CgroupDriverCheck struct {
+	runtime components.ContainerRuntimeForImage
+	// Expected is the cgroup driver of kubelet, systemd or cgroupfs.
+	Expected string
+	// ConfigPath defaults to /etc/containerd/config.toml, or /etc/docker/daemon.json for docker.
+	ConfigPath string
+}
+
+func (CgroupDriverCheck) Name() string {
+	return "CgroupDriver"
+}
+
+func (CgroupDriverCheck) DependsOn() []string {
+	return []string{"CRIStatus"}
+}
+
+func (cdc CgroupDriverCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"expected":   cdc.Expected,
+		"configPath": cdc.ConfigPath,
+	}
+}
+
+func (cdc CgroupDriverCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infof("validating cgroup driver of the container runtime is %s", cdc.Expected)
+
+	var driver, source string
+	config, err := cdc.runtime.RuntimeConfig()
+	if err == nil && config.CgroupDriver != "" {
+		driver, source = config.CgroupDriver, "reported by the container runtime"
+	} else {
+		klog.V(1).Infof("cgroup driver is not reported by the container runtime, falling back to the config file: %v", err)
+		path := cdc.ConfigPath
+		if path == "" {
+			path = containerdConfigPath
+			if cdc.runtime.IsDocker() {
+				path = dockerDaemonConfigPath
+			}
+		}
+		if driver, err = readCgroupDriverFromConfig(path, cdc.runtime.IsDocker()); err != nil {
+			return []error{errors.Wrap(err, "unable to determine the cgroup driver of the container runtime")}, nil
+		}
+		source = "read from " + path
+	}
+
+	if !strings.EqualFold(driver, cdc.Expected) {
+		return nil, []error{errors.Errorf("cgroup driver of the container runtime is %q (%s) instead of %q used by kubelet", driver, source, cdc.Expected)}
+	}
+	return nil, nil
+}
+
+// readCgroupDriverFromConfig returns the cgroup driver configured in the containerd config.toml,
+// or in the docker daemon.json when docker is true. cgroupfs is returned when none is configured.
+func readCgroupDriverFromConfig(path string, docker bool) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if !docker {
+		if systemdCgroup, _ := parseContainerdSystemdCgroup(string(data)); systemdCgroup {
+			return "systemd", nil
+		}
+		return "cgroupfs", nil
+	}
+
+	var daemon struct {
+		ExecOpts []string `json:"exec-opts"`
+	}
+	if err := json.Unmarshal(data, &daemon); err != nil {
+		return "", errors.Wrapf(err, "failed to parse %s", path)
+	}
+	for _, opt := range daemon.ExecOpts {
+		if driver := strings.TrimPrefix(opt, "native.cgroupdriver="); driver != opt {
+			return strings.TrimSpace(driver), nil
+		}
+	}
+	return "cgroupfs", nil
+}
+
+var containerdSystemdCgroupRegexp = regexp.MustCompile(`(?m)^\s*SystemdCgroup\s*=\s*(true|false)\s*$`)
+
+// parseContainerdSystemdCgroup returns the last SystemdCgroup value in a containerd config,
+// and whether it's set at all.
+func parseContainerdSystemdCgroup(config string) (systemdCgroup, set bool) {
+	matches := containerdSystemdCgroupRegexp.FindAllStringSubmatch(config, -1)
+	if len(matches) == 0 {
+		return false, false
+	}
+	return matches[len(matches)-1][1] == "true", true
+}
+
+var (
+	disabledPluginsRegexp = regexp.MustCompile(`(?ms)^\s*disabled_plugins\s*=\s*\[(.*?)\]`)
+	quotedStringRegexp    = regexp.MustCompile(`"([^"]*)"|'([^']*)'`)
+)
+
+// ContainerdCRIPluginCheck verifies that the CRI plugin is not disabled in the containerd config,
+// with which containerd keeps running but is unusable by kubelet. When the config doesn't exist,
+// the CRI runtime is queried instead. It's skipped for docker.
+type ContainerdCRIPluginCheck struct {
+	// ConfigPath defaults to /etc/containerd/config.toml.
+	ConfigPath string
+	runtime    components.ContainerRuntimeForImage
+}
+
+func (ContainerdCRIPluginCheck) Name() string {
+	return "ContainerdCRIPlugin"
+}
+
+func (ContainerdCRIPluginCheck) DependsOn() []string {
+	return []string{"CRIStatus"}
+}
+
+func (ccc ContainerdCRIPluginCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"configPath": ccc.ConfigPath}
+}
+
+func (ccc ContainerdCRIPluginCheck) Check() (warnings, errorList []error) {
+	if ccc.runtime != nil && ccc.runtime.IsDocker() {
+		return nil, nil
+	}
+	path := ccc.ConfigPath
+	if path == "" {
+		path = containerdConfigPath
+	}
+	klog.V(1).Infof("validating cri plugin is enabled in containerd config %s", path)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		if ccc.runtime == nil {
+			return nil, nil
+		}
+		if _, err := ccc.runtime.ListRuntimeHandlers(); err != nil {
+			return nil, []error{errors.Wrap(err, "containerd config doesn't exist, and the CRI runtime is not responding")}
+		}
+		return nil, nil
+	} else if err != nil {
+		return []error{errors.Wrapf(err, "unable to read containerd config %s", path)}, nil
+	}
+
+	for _, plugin := range parseDisabledPlugins(string(data)) {
+		if plugin == "cri" || plugin == "io.containerd.grpc.v1.cri" {
+			return nil, []error{errors.Errorf("cri plugin is disabled by disabled_plugins in containerd config %s, kubelet can't use containerd, please remove %q from it and restart containerd", path, plugin)}
+		}
+	}
+	return nil, nil
+}
+
+// parseDisabledPlugins returns the plugins listed in disabled_plugins of a containerd config.
+func parseDisabledPlugins(config string) []string {
+	match := disabledPluginsRegexp.FindStringSubmatch(config)
+	if match == nil {
+		return nil
+	}
+	var plugins []string
+	for _, quoted := range quotedStringRegexp.FindAllStringSubmatch(match[1], -1) {
+		plugins = append(plugins, quoted[1]+quoted[2])
+	}
+	return plugins
+}
+
+// ContainerdSystemdCgroupCheck verifies that SystemdCgroup of the runc runtime in the containerd
+// config matches the cgroup driver of kubelet, e.g. kubelet uses systemd but containerd has
+// SystemdCgroup = false. It's skipped when the containerd config doesn't exist.
+type ContainerdSystemdCgroupCheck struct {
+	// ConfigPath defaults to /etc/containerd/config.toml.
+	ConfigPath string
+	// ExpectedDriver is the cgroup driver of kubelet, systemd or cgroupfs, defaults to systemd.
+	ExpectedDriver string
+}
+
+func (ContainerdSystemdCgroupCheck) Name() string {
+	return "ContainerdSystemdCgroup"
+}
+
+func (csc ContainerdSystemdCgroupCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"configPath":     csc.ConfigPath,
+		"expectedDriver": csc.ExpectedDriver,
+	}
+}
+
+func (csc ContainerdSystemdCgroupCheck) Check() (warnings, errorList []error) {
+	path := csc.ConfigPath
+	if path == "" {
+		path = containerdConfigPath
+	}
+	expected := strings.ToLower(csc.ExpectedDriver)
+	if expected == "" {
+		expected = "systemd"
+	}
+	klog.V(1).Infof("validating SystemdCgroup in containerd config %s matches the %s cgroup driver", path, expected)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		klog.V(1).Infof("containerd config %s doesn't exist, skipping", path)
+		return nil, nil
+	} else if err != nil {
+		return []error{errors.Wrapf(err, "unable to read containerd config %s", path)}, nil
+	}
+
+	systemdCgroup, set := parseContainerdSystemdCgroup(string(data))
+	switch {
+	case expected == "systemd" && !systemdCgroup:
+		setting := "SystemdCgroup = false"
+		if !set {
+			setting = "SystemdCgroup unset, which defaults to false"
+		}
+		return nil, []error{errors.Errorf("kubelet uses the systemd cgroup driver, but containerd config %s has %s, please set SystemdCgroup = true and restart containerd", path, setting)}
+	case expected == "cgroupfs" && systemdCgroup:
+		return nil, []error{errors.Errorf("kubelet uses the cgroupfs cgroup driver, but containerd config %s has SystemdCgroup = true, please set SystemdCgroup = false and restart containerd", path)}
+	case expected != "systemd" && expected != "cgroupfs":
+		return nil, []error{errors.Errorf("unknown cgroup driver %q", csc.ExpectedDriver)}
+	}
+	return nil, nil
+}
+
+// CRIStatusCheck verifies the conditions reported by the CRI Status API of the container runtime.
+// It errors when the runtime is not ready, and warns when the network is not ready, which is
+// expected before join as the CNI is not installed yet.
+type CRIStatusCheck struct {
+	runtime components.ContainerRuntimeForImage
+}
+
+func (CRIStatusCheck) Name() string {
+	return "CRIStatus"
+}
+
+func (csc CRIStatusCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infoln("validating conditions of the container runtime")
+
+	conditions, err := csc.runtime.Status()
+	if err != nil {
+		return nil, []error{errors.Wrap(err, "failed to get status of the container runtime")}
+	}
+	runtimeReported := false
+	for _, condition := range conditions {
+		switch condition.Type {
+		case components.RuntimeReady:
+			runtimeReported = true
+			if !condition.Status {
+				errorList = append(errorList, errors.Errorf("container runtime is not ready, reason: %s, message: %s", condition.Reason, condition.Message))
+			}
+		case components.NetworkReady:
+			if !condition.Status {
+				warnings = append(warnings, errors.Errorf("container runtime network is not ready, which is expected if the CNI is not installed yet, reason: %s, message: %s", condition.Reason, condition.Message))
+			}
+		}
+	}
+	if !runtimeReported {
+		errorList = append(errorList, errors.Errorf("%s condition is not reported by the container runtime", components.RuntimeReady))
+	}
+	return warnings, errorList
+}
+
+// CRIVersionCheck verifies that the CRI API version served by the container runtime is not older
+// than MinVersion, an ancient runtime lacks methods kubelet calls. It's skipped for Docker, which
+// is served through dockershim.
+type CRIVersionCheck struct {
+	runtime components.ContainerRuntimeForImage
+	// MinVersion defaults to v1alpha2
+	MinVersion string
+}
+
+func (CRIVersionCheck) Name() string {
+	return "CRIVersion"
+}
+
+func (CRIVersionCheck) DependsOn() []string {
+	return []string{"CRIStatus"}
+}
+
+func (cvc CRIVersionCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"minVersion": cvc.MinVersion}
+}
+
+func (cvc CRIVersionCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infoln("validating CRI version of the container runtime")
+
+	if cvc.runtime.IsDocker() {
+		klog.V(1).Infoln("container runtime is docker, skipping CRI version check")
+		return nil, nil
+	}
+	v, err := cvc.runtime.Version()
+	if err != nil {
+		return nil, []error{errors.Wrap(err, "failed to get version of the container runtime")}
+	}
+	minVersion := cvc.MinVersion
+	if minVersion == "" {
+		minVersion = defaultMinCRIAPIVersion
+	}
+	if err := validateCRIAPIVersion(v.RuntimeAPIVersion, minVersion); err != nil {
+		return nil, []error{errors.Wrapf(err, "%s %s", v.RuntimeName, v.RuntimeVersion)}
+	}
+	return nil, nil
+}
+
+// validateCRIAPIVersion returns an error if actual is older than min. CRI API versions are
+// kube-like versions (e.g. v1alpha2, v1), anything else is considered older than them.
+func validateCRIAPIVersion(actual, min string) error {
+	if version.CompareKubeAwareVersionStrings(actual, min) < 0 {
+		return errors.Errorf("CRI API version %s is older than the minimum supported version %s", actual, min)
+	}
+	return nil
+}
+
+// CRISocketCheck verifies that the CRI socket is a unix socket accepting connections. It also warns
+// when the socket is writable by group or others, or is owned by root but not readable by its owner.
+type CRISocketCheck struct {
+	// Path is the CRI socket, with or without the unix:// scheme.
+	Path string
+}
+
+func (CRISocketCheck) Name() string {
+	return "CRISocket"
+}
+
+func (csc CRISocketCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"path": csc.Path}
+}
+
+func (csc CRISocketCheck) Check() (warnings, errorList []error) {
+	path := strings.TrimPrefix(csc.Path, "unix://")
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, []error{errors.Wrapf(err, "CRI socket %s is not found", path)}
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return nil, []error{errors.Errorf("CRI socket %s is not a socket", path)}
+	}
+	uid, hasOwner := fileOwner(info)
+	klog.V(1).Infof("validating CRI socket %s, mode %04o, owner uid %d", path, info.Mode().Perm(), uid)
+
+	warnings = socketPermissionWarnings(path, info.Mode().Perm(), uid, hasOwner)
+	conn, err := net.DialTimeout("unix", path, criSocketDialTimeout)
+	if err != nil {
+		return warnings, []error{errors.Wrapf(err, "failed to connect to CRI socket %s", path)}
+	}
+	conn.Close()
+	return warnings, nil
+}
+
+// Evidence returns the mode and owner of the CRI socket.
+func (csc CRISocketCheck) Evidence() string {
+	info, err := os.Stat(strings.TrimPrefix(csc.Path, "unix://"))
+	if err != nil {
+		return ""
+	}
+	evidence := fmt.Sprintf("mode: %s", info.Mode())
+	if uid, ok := fileOwner(info); ok {
+		evidence += fmt.Sprintf(", owner uid: %d", uid)
+	}
+	return evidence
+}
+
+// socketPermissionWarnings returns warnings about a socket writable by group or others, or owned by
+// root but not readable by its owner.
+func socketPermissionWarnings(path string, perm os.FileMode, uid int, hasOwner bool) []error {
+	var warnings []error
+	if perm&0022 != 0 {
+		warnings = append(warnings, errors.Errorf("CRI socket %s has mode %04o, it's writable by group or others, which allows them to control the container runtime", path, perm))
+	}
+	if hasOwner && uid == 0 && perm&0400 == 0 {
+		warnings = append(warnings, errors.Errorf("CRI socket %s has mode %04o, it's not readable by its owner root", path, perm))
+	}
+	return warnings
+}
+
+// DockershimCheck verifies that the CRI socket doesn't point at dockershim when the target
+// Kubernetes version no longer ships it.
+type DockershimCheck struct {
+	KubernetesVersion string
+	CRISocket         string
+}
+
+func (DockershimCheck) Name() string {
+	return "Dockershim"
+}
+
+func (dc DockershimCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"kubernetesVersion": dc.KubernetesVersion,
+		"criSocket":         dc.CRISocket,
+	}
+}
+
+func (dc DockershimCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infof("validating CRI socket %s for Kubernetes %s", dc.CRISocket, dc.KubernetesVersion)
+
+	if !strings.Contains(dc.CRISocket, "dockershim") {
+		return nil, nil
+	}
+	removed, err := dockershimRemoved(dc.KubernetesVersion)
+	if err != nil {
+		return nil, []error{err}
+	}
+	if removed {
+		return nil, []error{errors.Errorf("CRI socket %s is served by dockershim, which was removed in Kubernetes %s, please switch to a CRI compatible runtime such as containerd or cri-dockerd", dc.CRISocket, dockershimRemovedVersion)}
+	}
+	return nil, nil
+}
+
+// dockershimRemoved returns true if kubernetesVersion is not older than the version which removed dockershim.
+func dockershimRemoved(kubernetesVersion string) (bool, error) {
+	v, err := utilversion.ParseSemantic(kubernetesVersion)
+	if err != nil {
+		return false, errors.Wrapf(err, "invalid Kubernetes version %q", kubernetesVersion)
+	}
+	return v.AtLeast(utilversion.MustParseSemantic(dockershimRemovedVersion)), nil
+}
+
+// PreloadedImagesCheck verifies that the images of airgapped nodes are preloaded in the
+// container runtime, it never pulls them.
+type PreloadedImagesCheck struct {
+	runtime        components.ContainerRuntimeForImage
+	RequiredImages []string
+}
+
+func (PreloadedImagesCheck) Name() string {
+	return "PreloadedImages"
+}
+
+func (pic PreloadedImagesCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"requiredImages": pic.RequiredImages}
+}
+
+func (pic PreloadedImagesCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infof("validating images %v are preloaded", pic.RequiredImages)
+
+	for _, image := range pic.RequiredImages {
+		exists, err := pic.runtime.ImageExists(image)
+		if err != nil {
+			errorList = append(errorList, newCheckError(ReasonImageCheckFailed, "failed to check if image %s exists: %v", image, err))
+			continue
+		}
+		if !exists {
+			errorList = append(errorList, errors.Errorf("image %s is not found, it must be preloaded (e.g. by 'ctr -n k8s.io images import') as the node is airgapped", image))
+		}
+	}
+	return nil, errorList
+}
@@ -0,0 +1,330 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadCgroupDriverFromConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		docker   bool
+		expected string
+	}{
+		{
+			name:     "containerd with systemd cgroup",
+			content:  "[plugins.\"io.containerd.grpc.v1.cri\".containerd.runtimes.runc.options]\n  SystemdCgroup = true\n",
+			expected: "systemd",
+		},
+		{
+			name:     "containerd without systemd cgroup",
+			content:  "[plugins.\"io.containerd.grpc.v1.cri\".containerd.runtimes.runc.options]\n  SystemdCgroup = false\n",
+			expected: "cgroupfs",
+		},
+		{
+			name:     "containerd with SystemdCgroup overridden",
+			content:  "[plugins.\"io.containerd.grpc.v1.cri\".containerd.runtimes.runc.options]\n  SystemdCgroup = true\n  SystemdCgroup = false\n",
+			expected: "cgroupfs",
+		},
+		{
+			name:     "docker with systemd cgroup",
+			content:  `{"exec-opts": ["native.cgroupdriver=systemd"]}`,
+			docker:   true,
+			expected: "systemd",
+		},
+		{
+			name:     "docker without exec-opts",
+			content:  `{}`,
+			docker:   true,
+			expected: "cgroupfs",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			driver, err := readCgroupDriverFromConfig(path, tt.docker)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if driver != tt.expected {
+				t.Errorf("expected cgroup driver %s, got %s", tt.expected, driver)
+			}
+		})
+	}
+}
+
+func TestParseDisabledPlugins(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		expected []string
+	}{
+		{
+			name:     "cri disabled",
+			config:   "version = 2\ndisabled_plugins = [\"cri\"]\n",
+			expected: []string{"cri"},
+		},
+		{
+			name:     "multiple lines",
+			config:   "version = 2\ndisabled_plugins = [\n  \"io.containerd.grpc.v1.cri\",\n  'io.containerd.internal.v1.opt',\n]\n",
+			expected: []string{"io.containerd.grpc.v1.cri", "io.containerd.internal.v1.opt"},
+		},
+		{
+			name:     "none disabled",
+			config:   "version = 2\ndisabled_plugins = []\n",
+			expected: nil,
+		},
+		{
+			name:     "commented out",
+			config:   "version = 2\n# disabled_plugins = [\"cri\"]\n",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if plugins := parseDisabledPlugins(tt.config); !reflect.DeepEqual(plugins, tt.expected) {
+				t.Errorf("expected plugins %v, got %v", tt.expected, plugins)
+			}
+		})
+	}
+}
+
+func TestValidateCRIAPIVersion(t *testing.T) {
+	tests := []struct {
+		name      string
+		actual    string
+		min       string
+		expectErr bool
+	}{
+		{name: "equal", actual: "v1alpha2", min: "v1alpha2"},
+		{name: "ga newer than alpha", actual: "v1", min: "v1alpha2"},
+		{name: "alpha older than ga", actual: "v1alpha2", min: "v1", expectErr: true},
+		{name: "older alpha", actual: "v1alpha1", min: "v1alpha2", expectErr: true},
+		{name: "not kube-like", actual: "0.1.0", min: "v1alpha2", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCRIAPIVersion(tt.actual, tt.min)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("expected error %v, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestContainerdSystemdCgroupCheck(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       string
+		expected     string
+		expectErrors int
+	}{
+		{
+			name:     "missing config",
+			expected: "systemd",
+		},
+		{
+			name:     "systemd matches",
+			config:   "[plugins.\"io.containerd.grpc.v1.cri\".containerd.runtimes.runc.options]\n  SystemdCgroup = true\n",
+			expected: "systemd",
+		},
+		{
+			name:         "systemd conflicts",
+			config:       "[plugins.\"io.containerd.grpc.v1.cri\".containerd.runtimes.runc.options]\n  SystemdCgroup = false\n",
+			expected:     "systemd",
+			expectErrors: 1,
+		},
+		{
+			name:         "systemd with SystemdCgroup unset",
+			config:       "version = 2\n",
+			expectErrors: 1,
+		},
+		{
+			name:     "cgroupfs with SystemdCgroup unset",
+			config:   "version = 2\n",
+			expected: "cgroupfs",
+		},
+		{
+			name:         "cgroupfs conflicts",
+			config:       "  SystemdCgroup = true\n",
+			expected:     "cgroupfs",
+			expectErrors: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			if tt.config != "" {
+				if err := os.WriteFile(path, []byte(tt.config), 0644); err != nil {
+					t.Fatalf("failed to write file: %v", err)
+				}
+			}
+			warnings, errs := ContainerdSystemdCgroupCheck{ConfigPath: path, ExpectedDriver: tt.expected}.Check()
+			if len(warnings) != 0 || len(errs) != tt.expectErrors {
+				t.Errorf("expected no warnings and %d errors, got warnings %v, errors %v", tt.expectErrors, warnings, errs)
+			}
+		})
+	}
+}
+
+type fakeImageRuntime struct {
+	fakeRuntime
+	images map[string]bool
+}
+
+func (fir fakeImageRuntime) ImageExists(image string) (bool, error) {
+	return fir.images[image], nil
+}
+
+func TestCRISocketCheck(t *testing.T) {
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "containerd.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen on %s: %v", socket, err)
+	}
+	defer ln.Close()
+	if err := os.Chmod(socket, 0600); err != nil {
+		t.Fatalf("failed to chmod %s: %v", socket, err)
+	}
+	regular := filepath.Join(dir, "regular")
+	if err := os.WriteFile(regular, nil, 0600); err != nil {
+		t.Fatalf("failed to write %s: %v", regular, err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		expectErrs int
+	}{
+		{name: "listening socket", path: "unix://" + socket},
+		{name: "not a socket", path: regular, expectErrs: 1},
+		{name: "not found", path: filepath.Join(dir, "missing.sock"), expectErrs: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, errs := CRISocketCheck{Path: tt.path}.Check()
+			if len(warnings) != 0 || len(errs) != tt.expectErrs {
+				t.Errorf("expected no warnings and %d errors, got warnings %v, errors %v", tt.expectErrs, warnings, errs)
+			}
+		})
+	}
+}
+
+func TestSocketPermissionWarnings(t *testing.T) {
+	tests := []struct {
+		name           string
+		perm           os.FileMode
+		uid            int
+		hasOwner       bool
+		expectWarnings int
+	}{
+		{name: "owner only", perm: 0600, hasOwner: true},
+		{name: "group readable", perm: 0640, uid: 1000, hasOwner: true},
+		{name: "group writable", perm: 0660, hasOwner: true, expectWarnings: 1},
+		{name: "world writable", perm: 0666, hasOwner: true, expectWarnings: 1},
+		{name: "not readable by root", perm: 0200, hasOwner: true, expectWarnings: 1},
+		{name: "unknown owner", perm: 0200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if warnings := socketPermissionWarnings("/run/containerd/containerd.sock", tt.perm, tt.uid, tt.hasOwner); len(warnings) != tt.expectWarnings {
+				t.Errorf("expected %d warnings, got %v", tt.expectWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestDockershimCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		check      DockershimCheck
+		expectErrs int
+	}{
+		{
+			name:       "dockershim on removed version",
+			check:      DockershimCheck{KubernetesVersion: "v1.24.3", CRISocket: "unix:///var/run/dockershim.sock"},
+			expectErrs: 1,
+		},
+		{
+			name:  "dockershim on supported version",
+			check: DockershimCheck{KubernetesVersion: "v1.23.17", CRISocket: "/var/run/dockershim.sock"},
+		},
+		{
+			name:  "containerd on removed version",
+			check: DockershimCheck{KubernetesVersion: "v1.26.0", CRISocket: "/run/containerd/containerd.sock"},
+		},
+		{
+			name:       "invalid version",
+			check:      DockershimCheck{KubernetesVersion: "latest", CRISocket: "/var/run/dockershim.sock"},
+			expectErrs: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, errs := tt.check.Check()
+			if len(warnings) != 0 {
+				t.Errorf("expected no warnings, got %v", warnings)
+			}
+			if len(errs) != tt.expectErrs {
+				t.Errorf("expected %d errors, got %v", tt.expectErrs, errs)
+			}
+		})
+	}
+}
+
+func TestPreloadedImagesCheck(t *testing.T) {
+	runtime := fakeImageRuntime{images: map[string]bool{"openyurt/yurthub:v1.2.0": true}}
+	tests := []struct {
+		name         string
+		images       []string
+		expectErrors int
+	}{
+		{
+			name:   "all preloaded",
+			images: []string{"openyurt/yurthub:v1.2.0"},
+		},
+		{
+			name:         "missing image",
+			images:       []string{"openyurt/yurthub:v1.2.0", "openyurt/raven-agent:v0.3.0"},
+			expectErrors: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, errs := PreloadedImagesCheck{runtime: runtime, RequiredImages: tt.images}.Check()
+			if len(warnings) != 0 || len(errs) != tt.expectErrors {
+				t.Errorf("expected no warnings and %d errors, got warnings %v, errors %v", tt.expectErrors, warnings, errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,1404 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/klog/v2"
+	utilsexec "k8s.io/utils/exec"
+
+	"github.com/openyurtio/openyurt/pkg/yurtadm/constants"
+	"github.com/openyurtio/openyurt/pkg/yurtadm/util/initsystem"
+)
+
+const (
+	varLogDir   = "/var/log"
+	devShmDir   = "/dev/shm"
+	procSwaps   = "/proc/swaps"
+	zoneInfoDir = "/usr/share/zoneinfo"
+	zoneInfoUTC = "UTC"
+
+	initProcessEnviron = "/proc/1/environ"
+	dockerEnvFile      = "/.dockerenv"
+	dmiProductName     = "/sys/class/dmi/id/product_name"
+	dmiSysVendor       = "/sys/class/dmi/id/sys_vendor"
+	// apparmorCurrentLabel is the AppArmor profile confining the current process
+	apparmorCurrentLabel = "/proc/self/attr/current"
+
+	// systemdRuntimeDir only exists when systemd is running as the init system, see sd_booted(3)
+	systemdRuntimeDir = "/run/systemd/system"
+)
+
+// ServiceSpec describes a service the node requires.
+type ServiceSpec struct {
+	// Name is the name of the service in the init system, e.g. kubelet.
+	Name string `yaml:"name"`
+	// MustBeActive makes an inactive service an error instead of a warning.
+	MustBeActive bool `yaml:"mustBeActive,omitempty"`
+	// ConflictIfRunning reverses the desired state, e.g. for kubelet during join: a service that is
+	// enabled and active is a leftover of a previous install and is warned about, while a stopped
+	// one is fine. MustBeActive is ignored when it's set.
+	ConflictIfRunning bool `yaml:"conflictIfRunning,omitempty"`
+	// Label is used in messages instead of Name when set.
+	Label string `yaml:"label,omitempty"`
+}
+
+func (ss ServiceSpec) label() string {
+	if ss.Label != "" {
+		return ss.Label
+	}
+	return ss.Name
+}
+
+// RequiredServicesCheck verifies the state of all the services the node requires in one pass.
+// If the init system is not supported, all the services are skipped and a warning is returned.
+type RequiredServicesCheck struct {
+	Services []ServiceSpec
+}
+
+func (RequiredServicesCheck) Name() string {
+	return "RequiredServices"
+}
+
+func (rsc RequiredServicesCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"services": rsc.Services}
+}
+
+func (rsc RequiredServicesCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infoln("validating required services")
+
+	initSystem, err := detectInitSystem()
+	if err != nil {
+		return []error{err}, nil
+	}
+
+	for _, spec := range rsc.Services {
+		w, e := checkService(initSystem, spec)
+		warnings = append(warnings, w...)
+		errorList = append(errorList, e...)
+	}
+	return warnings, errorList
+}
+
+// checkService verifies the state of a single service against its spec.
+func checkService(initSystem initsystem.InitSystem, spec ServiceSpec) (warnings, errorList []error) {
+	enabled, active := initSystem.ServiceIsEnabled(spec.Name), initSystem.ServiceIsActive(spec.Name)
+	if spec.ConflictIfRunning {
+		if enabled && active {
+			warnings = append(warnings, errors.Errorf("existing %s service detected, it will conflict with the new configuration, reset recommended", spec.label()))
+		}
+		return warnings, nil
+	}
+
+	if !enabled {
+		warnings = append(warnings, errors.Errorf("%s service is not enabled, please run 'systemctl enable %s.service'", spec.label(), spec.Name))
+	}
+	if !active {
+		if spec.MustBeActive {
+			errorList = append(errorList, errors.Errorf("%s service is not active, please run 'systemctl start %s.service'", spec.label(), spec.Name))
+		} else {
+			warnings = append(warnings, errors.Errorf("%s service is not active", spec.label()))
+		}
+	}
+	return warnings, errorList
+}
+
+// detectInitSystem returns the init system of the node. The returned error means the init system
+// is not supported, and the checks relying on it should be skipped with the error as a warning
+// rather than failing. Besides the detection of initsystem.GetInitSystem, systemctl being in
+// $PATH while systemd isn't running (e.g. in containers) is also treated as unsupported.
+func detectInitSystem() (initsystem.InitSystem, error) {
+	initSystem, err := initsystem.GetInitSystem()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkInitSystemRunning(initSystem, systemdRuntimeDir); err != nil {
+		return nil, err
+	}
+	return initSystem, nil
+}
+
+// checkInitSystemRunning returns an error if initSystem is systemd but runtimeDir, which only exists
+// when systemd is running, is missing.
+func checkInitSystemRunning(initSystem initsystem.InitSystem, runtimeDir string) error {
+	if _, ok := initSystem.(*initsystem.SystemdInitSystem); ok {
+		if _, err := os.Stat(runtimeDir); err != nil {
+			return errors.New("systemctl is found but systemd is not running as init system, skipping checking for services")
+		}
+	}
+	return nil
+}
+
+// VarLogCheck verifies that /var/log exists, is writable and has at least MinBytes free space.
+type VarLogCheck struct {
+	MinBytes uint64
+}
+
+func (VarLogCheck) Name() string {
+	return "VarLog"
+}
+
+func (vlc VarLogCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"minBytes": vlc.MinBytes}
+}
+
+func (vlc VarLogCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infof("validating %s", varLogDir)
+	return checkLogDir(varLogDir, vlc.MinBytes)
+}
+
+// checkLogDir verifies that dir is a writable directory with at least minBytes free.
+func checkLogDir(dir string, minBytes uint64) (warnings, errorList []error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, []error{errors.Wrapf(err, "unable to stat %s", dir)}
+	}
+	if !info.IsDir() {
+		return nil, []error{errors.Errorf("%s is not a directory", dir)}
+	}
+	if err := isDirWritable(dir); err != nil {
+		return nil, []error{errors.Wrapf(err, "%s is not writable", dir)}
+	}
+
+	available, err := getAvailableBytes(dir)
+	if err != nil {
+		return []error{errors.Wrapf(err, "unable to get free space of %s", dir)}, nil
+	}
+	if available < minBytes {
+		warnings = append(warnings, errors.Errorf("%s has %d bytes free, which is less than the recommended %d bytes", dir, available, minBytes))
+	}
+	return warnings, nil
+}
+
+const (
+	// defaultMaxSwapUsagePercent is the swap usage above which SwapCheck warns in tolerant mode
+	defaultMaxSwapUsagePercent = 50
+)
+
+// SwapCheck verifies that swap is disabled, as required by kubelet by default. In tolerant mode,
+// for nodes intentionally running with swap (NodeSwap feature), it warns instead when the swap
+// usage is already above MaxUsagePercent.
+type SwapCheck struct {
+	Tolerate bool
+	// MaxUsagePercent defaults to 50.
+	MaxUsagePercent int
+}
+
+func (SwapCheck) Name() string {
+	return "Swap"
+}
+
+func (sc SwapCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"tolerate":        sc.Tolerate,
+		"maxUsagePercent": sc.MaxUsagePercent,
+	}
+}
+
+func (sc SwapCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infoln("validating swap")
+
+	content, err := os.ReadFile(procSwaps)
+	if err != nil {
+		return []error{errors.Wrapf(err, "unable to read %s", procSwaps)}, nil
+	}
+	swaps := parseSwaps(string(content))
+	if len(swaps) == 0 {
+		return nil, nil
+	}
+	if !sc.Tolerate {
+		return nil, []error{newCheckError(ReasonSwapEnabled, "swap is enabled, please disable swap")}
+	}
+
+	maxUsage := sc.MaxUsagePercent
+	if maxUsage == 0 {
+		maxUsage = defaultMaxSwapUsagePercent
+	}
+	var size, used uint64
+	for _, swap := range swaps {
+		size += swap.Size
+		used += swap.Used
+	}
+	if size != 0 && used*100/size > uint64(maxUsage) {
+		warnings = append(warnings, errors.Errorf("swap is already %d%% used (%d of %d KiB), which is above %d%%", used*100/size, used, size, maxUsage))
+	}
+	return warnings, nil
+}
+
+// Evidence returns the content of /proc/swaps.
+func (SwapCheck) Evidence() string {
+	content, err := os.ReadFile(procSwaps)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// swapEntry is a line of /proc/swaps, sizes are in KiB.
+type swapEntry struct {
+	Filename string
+	Size     uint64
+	Used     uint64
+}
+
+// parseSwaps parses the content of /proc/swaps, skipping the header line.
+func parseSwaps(content string) []swapEntry {
+	var swaps []swapEntry
+	for i, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) < 4 {
+			continue
+		}
+		size, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		used, err := strconv.ParseUint(fields[3], 10, 64)
+		if err != nil {
+			continue
+		}
+		swaps = append(swaps, swapEntry{Filename: unescapeMountPath(fields[0]), Size: size, Used: used})
+	}
+	return swaps
+}
+
+// DevShmCheck verifies that /dev/shm is at least MinBytes large, the small default size
+// in some constrained setups breaks the workloads relying on shared memory.
+type DevShmCheck struct {
+	MinBytes uint64
+}
+
+func (DevShmCheck) Name() string {
+	return "DevShm"
+}
+
+func (dsc DevShmCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"minBytes": dsc.MinBytes}
+}
+
+func (dsc DevShmCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infof("validating size of %s", devShmDir)
+
+	size, err := devShmSize()
+	if err != nil {
+		return []error{errors.Wrapf(err, "unable to get size of %s", devShmDir)}, nil
+	}
+	if size < dsc.MinBytes {
+		warnings = append(warnings, errors.Errorf("%s is %d bytes, which is less than the recommended %d bytes", devShmDir, size, dsc.MinBytes))
+	}
+	return warnings, nil
+}
+
+// devShmSize returns the size of /dev/shm from the size option of its mount,
+// and falls back to statfs when the option is absent or relative to memory.
+func devShmSize() (uint64, error) {
+	if mounts, err := readMounts(); err == nil {
+		for _, m := range mounts {
+			if m.MountPoint != devShmDir {
+				continue
+			}
+			for _, opt := range m.Options {
+				if size, ok := parseTmpfsSize(opt); ok {
+					return size, nil
+				}
+			}
+		}
+	}
+	return getTotalBytes(devShmDir)
+}
+
+// parseTmpfsSize parses the size option of a tmpfs mount, e.g. size=65536k.
+// Sizes in percentage of memory are not supported.
+func parseTmpfsSize(opt string) (uint64, bool) {
+	value := strings.TrimPrefix(opt, "size=")
+	if value == opt || value == "" {
+		return 0, false
+	}
+	multiplier := uint64(1)
+	switch value[len(value)-1] {
+	case 'k', 'K':
+		multiplier = 1 << 10
+	case 'm', 'M':
+		multiplier = 1 << 20
+	case 'g', 'G':
+		multiplier = 1 << 30
+	case 't', 'T':
+		multiplier = 1 << 40
+	}
+	if multiplier != 1 {
+		value = value[:len(value)-1]
+	}
+	size, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return size * multiplier, true
+}
+
+// RootFSWritableCheck detects a read-only root filesystem, as used by immutable or OSTree based OSes,
+// and errors for every path in RequiredWritablePaths that lives on it rather than on a writable mount.
+type RootFSWritableCheck struct {
+	RequiredWritablePaths []string
+}
+
+func (RootFSWritableCheck) Name() string {
+	return "RootFSWritable"
+}
+
+func (rwc RootFSWritableCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"requiredWritablePaths": rwc.RequiredWritablePaths}
+}
+
+func (rwc RootFSWritableCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infof("validating paths %v are writable", rwc.RequiredWritablePaths)
+
+	mounts, err := readMounts()
+	if err != nil {
+		return nil, []error{errors.Wrapf(err, "unable to read %s", procMountsPath)}
+	}
+	root := findMount("/", mounts)
+	if root == nil || !hasMountOption(root, "ro") {
+		return nil, nil
+	}
+	klog.V(1).Infoln("root filesystem is mounted read-only")
+
+	for _, path := range rwc.RequiredWritablePaths {
+		if m := findMount(path, mounts); m != nil && m.MountPoint == "/" {
+			errorList = append(errorList, errors.Errorf("%s is on the read-only root filesystem, please mount a writable filesystem on it or relocate it under /var or /etc", path))
+		}
+	}
+	return nil, errorList
+}
+
+// defaultDataDirs are the data directories of the container runtime and kubelet.
+var defaultDataDirs = []string{"/var/lib/containerd", constants.KubeletWorkdir}
+
+// DataDirPartitionCheck warns when the data directories of the container runtime and kubelet
+// have no dedicated mount and live on the root filesystem, which is often a tiny SD card
+// partition on edge devices. It only runs when WarnIfSameAsRoot is set.
+type DataDirPartitionCheck struct {
+	// Paths defaults to /var/lib/containerd and /var/lib/kubelet.
+	Paths            []string
+	WarnIfSameAsRoot bool
+}
+
+func (DataDirPartitionCheck) Name() string {
+	return "DataDirPartition"
+}
+
+func (ddc DataDirPartitionCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"paths":            ddc.Paths,
+		"warnIfSameAsRoot": ddc.WarnIfSameAsRoot,
+	}
+}
+
+func (ddc DataDirPartitionCheck) Check() (warnings, errorList []error) {
+	if !ddc.WarnIfSameAsRoot {
+		return nil, nil
+	}
+	paths := ddc.Paths
+	if len(paths) == 0 {
+		paths = defaultDataDirs
+	}
+	klog.V(1).Infof("validating data dirs %v are not on the root filesystem", paths)
+
+	mounts, err := readMounts()
+	if err != nil {
+		return []error{errors.Wrapf(err, "unable to read %s", procMountsPath)}, nil
+	}
+	resolved := make([]string, 0, len(paths))
+	for _, path := range paths {
+		// follow the symlinks relocating data dirs onto other filesystems
+		if target, err := filepath.EvalSymlinks(path); err == nil {
+			path = target
+		}
+		resolved = append(resolved, path)
+	}
+	onRoot := pathsOnRootFS(resolved, mounts)
+	if len(onRoot) == 0 {
+		return nil, nil
+	}
+
+	size := "unknown size"
+	if total, err := getTotalBytes("/"); err == nil {
+		size = fmt.Sprintf("%d MiB", total>>20)
+	}
+	return []error{errors.Errorf("%s have no dedicated mount and are on the root filesystem (%s), consider mounting a larger partition on them", strings.Join(onRoot, ", "), size)}, nil
+}
+
+// pathsOnRootFS returns the paths living on the filesystem mounted on "/".
+func pathsOnRootFS(paths []string, mounts []mountEntry) []string {
+	var onRoot []string
+	for _, path := range paths {
+		if m := findMount(path, mounts); m != nil && m.MountPoint == "/" {
+			onRoot = append(onRoot, path)
+		}
+	}
+	return onRoot
+}
+
+const procSelfMountInfo = "/proc/self/mountinfo"
+
+// MountPropagationCheck warns when the mount Path lives on is not shared, then volumes with
+// Bidirectional mount propagation and CSI drivers don't work.
+type MountPropagationCheck struct {
+	// Path defaults to /var/lib/kubelet.
+	Path string
+}
+
+func (MountPropagationCheck) Name() string {
+	return "MountPropagation"
+}
+
+func (mpc MountPropagationCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"path": mpc.Path}
+}
+
+func (mpc MountPropagationCheck) Check() (warnings, errorList []error) {
+	path := mpc.Path
+	if path == "" {
+		path = constants.KubeletWorkdir
+	}
+	klog.V(1).Infof("validating mount propagation of %s", path)
+
+	content, err := os.ReadFile(procSelfMountInfo)
+	if err != nil {
+		return []error{errors.Wrapf(err, "unable to read %s", procSelfMountInfo)}, nil
+	}
+	m := findMountInfo(path, parseMountInfo(string(content)))
+	if m == nil {
+		return []error{errors.Errorf("no mount is found for %s in %s", path, procSelfMountInfo)}, nil
+	}
+	if !m.Shared {
+		return []error{errors.Errorf("mount %s of %s is private, Bidirectional mount propagation and CSI drivers will not work, please run 'mount --make-rshared %s'", m.MountPoint, path, m.MountPoint)}, nil
+	}
+	return nil, nil
+}
+
+// mountInfoEntry is a line of /proc/self/mountinfo.
+type mountInfoEntry struct {
+	MountPoint string
+	// Shared is true if the mount is in a peer group, i.e. has the shared:N optional field.
+	Shared bool
+}
+
+// parseMountInfo parses the content of /proc/self/mountinfo, e.g.
+// "36 35 98:0 / /var/lib/kubelet rw,noatime shared:1 - ext4 /dev/sda2 rw".
+func parseMountInfo(content string) []mountInfoEntry {
+	var entries []mountInfoEntry
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 7 {
+			continue
+		}
+		entry := mountInfoEntry{MountPoint: unescapeMountPath(fields[4])}
+		for _, field := range fields[6:] {
+			if field == "-" {
+				break
+			}
+			if strings.HasPrefix(field, "shared:") {
+				entry.Shared = true
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// findMountInfo returns the mount path lives on, i.e. the last mounted one with the longest mount point containing path.
+func findMountInfo(path string, entries []mountInfoEntry) *mountInfoEntry {
+	var found *mountInfoEntry
+	for i := range entries {
+		e := &entries[i]
+		if !isSubPath(path, e.MountPoint) {
+			continue
+		}
+		if found == nil || len(filepath.Clean(e.MountPoint)) >= len(filepath.Clean(found.MountPoint)) {
+			found = e
+		}
+	}
+	return found
+}
+
+// findMount returns the mount path lives on, i.e. the last mounted one with the longest mount point containing path.
+func findMount(path string, mounts []mountEntry) *mountEntry {
+	var found *mountEntry
+	for i := range mounts {
+		m := &mounts[i]
+		if !isSubPath(path, m.MountPoint) {
+			continue
+		}
+		if found == nil || len(filepath.Clean(m.MountPoint)) >= len(filepath.Clean(found.MountPoint)) {
+			found = m
+		}
+	}
+	return found
+}
+
+func hasMountOption(m *mountEntry, option string) bool {
+	for _, opt := range m.Options {
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+// LocaleCheck warns when the locale of the environment is not a UTF-8 one, which occasionally
+// breaks number and date parsing in the shell driven setup steps.
+type LocaleCheck struct{}
+
+func (LocaleCheck) Name() string {
+	return "Locale"
+}
+
+func (LocaleCheck) Check() (warnings, errorList []error) {
+	name, locale := effectiveLocale(os.Getenv)
+	klog.V(1).Infof("validating locale %s=%s", name, locale)
+
+	normalized := strings.ToLower(locale)
+	if !strings.Contains(normalized, "utf-8") && !strings.Contains(normalized, "utf8") {
+		if locale == "" {
+			locale = "POSIX"
+		}
+		return []error{errors.Errorf("locale %s is not a UTF-8 locale, please consider setting LANG=C.UTF-8", locale)}, nil
+	}
+	return nil, nil
+}
+
+// effectiveLocale returns the variable defining the character set of the locale and its value,
+// following the precedence LC_ALL > LC_CTYPE > LANG.
+func effectiveLocale(getenv func(string) string) (string, string) {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if value := getenv(name); value != "" {
+			return name, value
+		}
+	}
+	return "LANG", ""
+}
+
+// TimeZoneDBCheck verifies that the time zone database is present, which is needed by
+// time.LoadLocation in workloads and components.
+type TimeZoneDBCheck struct{}
+
+func (TimeZoneDBCheck) Name() string {
+	return "TimeZoneDB"
+}
+
+func (TimeZoneDBCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infof("validating time zone database %s", zoneInfoDir)
+
+	if err := checkZoneInfoDir(zoneInfoDir); err != nil {
+		return []error{err}, nil
+	}
+	return nil, nil
+}
+
+// checkZoneInfoDir verifies that the time zone database dir exists and contains the UTC zone.
+func checkZoneInfoDir(dir string) error {
+	if _, err := os.Stat(dir); err != nil {
+		return errors.Errorf("time zone database %s doesn't exist, please install the tzdata package", dir)
+	}
+	if _, err := os.Stat(filepath.Join(dir, zoneInfoUTC)); err != nil {
+		return errors.Errorf("time zone %s is missing in %s, please install the tzdata package", zoneInfoUTC, dir)
+	}
+	return nil
+}
+
+var (
+	gitVersionRegexp = regexp.MustCompile(`GitVersion:"(v[^"]+)"`)
+	semVersionRegexp = regexp.MustCompile(`v\d+\.\d+\.\d+\S*`)
+)
+
+// BinaryVersionCheck verifies that the version of a Kubernetes binary (kubeadm, kubectl, kubelet)
+// on the node doesn't diverge from the target Kubernetes version by more than one minor version.
+type BinaryVersionCheck struct {
+	Binary          string
+	ExpectedVersion string
+	exec            utilsexec.Interface
+}
+
+func (bvc BinaryVersionCheck) Name() string {
+	return fmt.Sprintf("BinaryVersion-%s", bvc.Binary)
+}
+
+func (bvc BinaryVersionCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"binary":          bvc.Binary,
+		"expectedVersion": bvc.ExpectedVersion,
+	}
+}
+
+func (bvc BinaryVersionCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infof("validating version of %s", bvc.Binary)
+
+	expected, err := version.ParseSemantic(bvc.ExpectedVersion)
+	if err != nil {
+		return nil, []error{errors.Wrapf(err, "couldn't parse expected version %q", bvc.ExpectedVersion)}
+	}
+
+	out, err := bvc.exec.Command(bvc.Binary, binaryVersionArgs(bvc.Binary)...).CombinedOutput()
+	if err != nil {
+		return []error{errors.Wrapf(err, "couldn't get version of %s, output: %s", bvc.Binary, out)}, nil
+	}
+	actual, err := parseBinaryVersion(string(out))
+	if err != nil {
+		return []error{errors.Wrapf(err, "couldn't parse version of %s", bvc.Binary)}, nil
+	}
+
+	minorSkew := int(actual.Minor()) - int(expected.Minor())
+	if actual.Major() != expected.Major() || minorSkew > 1 || minorSkew < -1 {
+		return []error{errors.Errorf("%s version %s diverges from the target Kubernetes version %s by more than one minor version", bvc.Binary, actual, expected)}, nil
+	}
+	return nil, nil
+}
+
+// binaryVersionArgs returns the arguments to print the version of the given binary,
+// because kubelet, kubeadm and kubectl don't share the same version command.
+func binaryVersionArgs(binary string) []string {
+	switch filepath.Base(binary) {
+	case "kubelet":
+		return []string{"--version"}
+	case "kubeadm":
+		return []string{"version", "-o", "short"}
+	case "kubectl":
+		return []string{"version", "--client"}
+	default:
+		return []string{"version"}
+	}
+}
+
+// parseBinaryVersion extracts the version from the output of a version command, it handles
+// both the version.Info struct output (GitVersion:"v1.22.3") and plain semantic versions.
+func parseBinaryVersion(out string) (*version.Version, error) {
+	if matches := gitVersionRegexp.FindStringSubmatch(out); len(matches) == 2 {
+		return version.ParseSemantic(matches[1])
+	}
+	if match := semVersionRegexp.FindString(out); match != "" {
+		return version.ParseSemantic(match)
+	}
+	return nil, errors.Errorf("no version found in output %q", out)
+}
+
+// problematicVirtualizations are the environments in which kubelet is known to have
+// trouble with cgroup and mount operations, along with the hint to fix them.
+// LXC containers are only problematic without nesting, see lxcNestingEnabled.
+var problematicVirtualizations = map[string]string{
+	"lxc":         "nesting is not enabled for the LXC container, please enable it (e.g. security.nesting=true)",
+	"lxc-libvirt": "nesting is not enabled for the LXC container, please enable it",
+	"openvz":      "OpenVZ containers don't support cgroup delegation required by kubelet",
+	"docker":      "running kubelet inside a docker container requires privileged mode and host cgroup mounts",
+	"podman":      "running kubelet inside a podman container requires privileged mode and host cgroup mounts",
+	"wsl":         "WSL doesn't provide a full init and cgroup environment for kubelet",
+}
+
+// VirtualizationCheck detects the virtualization or container environment the node is running in,
+// and warns for the environments known to break kubelet, e.g. LXC containers without nesting.
+type VirtualizationCheck struct {
+	exec utilsexec.Interface
+}
+
+func (VirtualizationCheck) Name() string {
+	return "Virtualization"
+}
+
+func (vc VirtualizationCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infoln("validating virtualization environment")
+
+	virt := detectVirtualization(vc.exec)
+	hint, ok := problematicVirtualizations[virt]
+	if ok && strings.HasPrefix(virt, "lxc") {
+		label, _ := os.ReadFile(apparmorCurrentLabel)
+		mounts, _ := readMounts()
+		ok = !lxcNestingEnabled(strings.TrimSpace(string(label)), mounts)
+	}
+	if ok {
+		return []error{errors.Errorf("node is running in %s environment, %s", virt, hint)}, nil
+	}
+	klog.V(1).Infof("node is running in %q virtualization environment", virt)
+	return nil, nil
+}
+
+// lxcNestingEnabled tells a nested LXC container apart by its AppArmor profile, which is a
+// nesting one (e.g. lxc-container-default-with-nesting), or by /proc/sys not being mounted
+// read-only, as LXC does for containers without nesting.
+func lxcNestingEnabled(apparmorLabel string, mounts []mountEntry) bool {
+	if strings.Contains(apparmorLabel, "nesting") {
+		return true
+	}
+	readOnly := false
+	for _, m := range mounts {
+		// the last mount on /proc/sys shadows the earlier ones
+		if m.MountPoint != "/proc/sys" {
+			continue
+		}
+		readOnly = false
+		for _, opt := range m.Options {
+			if opt == "ro" {
+				readOnly = true
+			}
+		}
+	}
+	return !readOnly
+}
+
+// detectVirtualization prefers systemd-detect-virt, and falls back to the container
+// variable of the init process and the DMI product name and vendor.
+func detectVirtualization(execer utilsexec.Interface) string {
+	if _, err := execer.LookPath("systemd-detect-virt"); err == nil {
+		// systemd-detect-virt exits with 1 and prints "none" when no virtualization is detected
+		out, _ := execer.Command("systemd-detect-virt").Output()
+		if virt := strings.TrimSpace(string(out)); virt != "" {
+			return virt
+		}
+	}
+
+	if environ, err := os.ReadFile(initProcessEnviron); err == nil {
+		for _, env := range strings.Split(string(environ), "\x00") {
+			if strings.HasPrefix(env, "container=") {
+				return strings.TrimPrefix(env, "container=")
+			}
+		}
+	}
+	if _, err := os.Stat(dockerEnvFile); err == nil {
+		return "docker"
+	}
+	product, _ := os.ReadFile(dmiProductName)
+	vendor, _ := os.ReadFile(dmiSysVendor)
+	return dmiVirtualization(strings.TrimSpace(string(product)), strings.TrimSpace(string(vendor)))
+}
+
+// dmiVirtualizations maps the prefixes of the DMI product name or vendor of known hypervisors
+// to the ids systemd-detect-virt reports for them, see src/basic/virt.c of systemd.
+var dmiVirtualizations = []struct {
+	prefix string
+	virt   string
+}{
+	{prefix: "KVM", virt: "kvm"},
+	{prefix: "OpenStack", virt: "kvm"},
+	{prefix: "KubeVirt", virt: "kvm"},
+	{prefix: "Amazon EC2", virt: "amazon"},
+	{prefix: "QEMU", virt: "qemu"},
+	{prefix: "VMware", virt: "vmware"},
+	{prefix: "VMW", virt: "vmware"},
+	{prefix: "innotek GmbH", virt: "oracle"},
+	{prefix: "VirtualBox", virt: "oracle"},
+	{prefix: "Oracle Corporation", virt: "oracle"},
+	{prefix: "Xen", virt: "xen"},
+	{prefix: "Bochs", virt: "bochs"},
+	{prefix: "Parallels", virt: "parallels"},
+	{prefix: "BHYVE", virt: "bhyve"},
+	{prefix: "Hyper-V", virt: "microsoft"},
+	{prefix: "Google", virt: "google"},
+}
+
+// dmiVirtualization returns the systemd id of the hypervisor the DMI product name or vendor
+// belongs to, or "none" when it's not a known one. A Microsoft Virtual Machine is Hyper-V.
+func dmiVirtualization(product, vendor string) string {
+	if vendor == "Microsoft Corporation" && product == "Virtual Machine" {
+		return "microsoft"
+	}
+	for _, field := range []string{product, vendor} {
+		for _, dv := range dmiVirtualizations {
+			if strings.HasPrefix(field, dv.prefix) {
+				return dv.virt
+			}
+		}
+	}
+	return "none"
+}
+
+// HostnameStabilityCheck verifies that the runtime hostname is the one persisted in /etc/hostname,
+// a transient (e.g. DHCP assigned) hostname makes the node re-register under a new name after reboot.
+type HostnameStabilityCheck struct{}
+
+func (HostnameStabilityCheck) Name() string {
+	return "HostnameStability"
+}
+
+func (HostnameStabilityCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infoln("validating hostname stability")
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, []error{errors.Wrap(err, "failed to get hostname")}
+	}
+
+	content, err := os.ReadFile(constants.Hostname)
+	if err != nil {
+		return []error{errors.Wrapf(err, "unable to read %s, hostname %s may not persist across reboots", constants.Hostname, hostname)}, nil
+	}
+	if err := validateStaticHostname(hostname, string(content)); err != nil {
+		return []error{err}, nil
+	}
+	return nil, nil
+}
+
+// validateStaticHostname verifies that hostname matches the static hostname in content of /etc/hostname.
+func validateStaticHostname(hostname, content string) error {
+	static := strings.TrimSpace(content)
+	if !strings.EqualFold(static, hostname) {
+		return errors.Errorf("hostname %q differs from %q in %s, it may be transient and change after reboot", hostname, static, constants.Hostname)
+	}
+	return nil
+}
+
+var systemdVersionRegexp = regexp.MustCompile(`systemd (\d+)`)
+
+// SystemdVersionCheck verifies that systemd is not older than Min, older versions lack
+// the cgroup delegation features kubelet relies on. It's skipped on non-systemd init systems.
+type SystemdVersionCheck struct {
+	Min  int
+	exec utilsexec.Interface
+}
+
+func (SystemdVersionCheck) Name() string {
+	return "SystemdVersion"
+}
+
+func (svc SystemdVersionCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"min": svc.Min}
+}
+
+func (svc SystemdVersionCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infoln("validating systemd version")
+
+	initSystem, err := detectInitSystem()
+	if err != nil {
+		return []error{err}, nil
+	}
+	if _, ok := initSystem.(*initsystem.SystemdInitSystem); !ok {
+		klog.V(1).Infoln("init system is not systemd, skipping systemd version check")
+		return nil, nil
+	}
+
+	out, err := svc.exec.Command("systemctl", "--version").Output()
+	if err != nil {
+		return []error{errors.Wrap(err, "failed to get systemd version")}, nil
+	}
+	version, err := parseSystemdVersion(string(out))
+	if err != nil {
+		return []error{err}, nil
+	}
+	if version < svc.Min {
+		return []error{errors.Errorf("systemd version %d is older than the minimum supported version %d", version, svc.Min)}, nil
+	}
+	return nil, nil
+}
+
+// parseSystemdVersion parses the leading version of `systemctl --version` output, e.g. "systemd 249 (249.11-0ubuntu3)".
+func parseSystemdVersion(out string) (int, error) {
+	matches := systemdVersionRegexp.FindStringSubmatch(out)
+	if len(matches) != 2 {
+		return 0, errors.Errorf("couldn't parse systemd version from output %q", out)
+	}
+	return strconv.Atoi(matches[1])
+}
+
+// CapturedHostname holds the hostname captured by HostnameCheck, it's shared with
+// HostnameUnchangedCheck to detect hostname changes during the run, e.g. by racy DHCP.
+type CapturedHostname struct {
+	mu       sync.RWMutex
+	hostname string
+}
+
+// Hostname returns the captured hostname, or an empty string if none is captured yet.
+func (ch *CapturedHostname) Hostname() string {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+	return ch.hostname
+}
+
+func (ch *CapturedHostname) set(hostname string) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.hostname = hostname
+}
+
+// HostnameCheck captures the hostname of the node into Captured, it should run first.
+type HostnameCheck struct {
+	Captured *CapturedHostname
+	// hostname defaults to os.Hostname.
+	hostname func() (string, error)
+}
+
+func (HostnameCheck) Name() string {
+	return "Hostname"
+}
+
+func (hc HostnameCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infoln("capturing hostname")
+
+	hostname, err := getHostname(hc.hostname)
+	if err != nil {
+		return nil, []error{errors.Wrap(err, "failed to get hostname")}
+	}
+	if hostname == "" {
+		return nil, []error{errors.New("hostname is empty")}
+	}
+	if hc.Captured != nil {
+		hc.Captured.set(hostname)
+	}
+	return nil, nil
+}
+
+// HostnameUnchangedCheck warns when the hostname differs from the one captured by HostnameCheck,
+// it should run last. It's skipped when no hostname is captured.
+type HostnameUnchangedCheck struct {
+	Captured *CapturedHostname
+	// hostname defaults to os.Hostname.
+	hostname func() (string, error)
+}
+
+func (HostnameUnchangedCheck) Name() string {
+	return "HostnameUnchanged"
+}
+
+func (HostnameUnchangedCheck) DependsOn() []string {
+	return []string{"Hostname"}
+}
+
+func (huc HostnameUnchangedCheck) Check() (warnings, errorList []error) {
+	if huc.Captured == nil || huc.Captured.Hostname() == "" {
+		klog.V(1).Infoln("no hostname is captured, skipping")
+		return nil, nil
+	}
+	klog.V(1).Infoln("validating hostname is unchanged")
+
+	captured := huc.Captured.Hostname()
+	hostname, err := getHostname(huc.hostname)
+	if err != nil {
+		return []error{errors.Wrap(err, "failed to get hostname")}, nil
+	}
+	if hostname != captured {
+		return []error{errors.Errorf("hostname changed from %q to %q during the run, kubelet may register the node under an unexpected name, please make the hostname static", captured, hostname)}, nil
+	}
+	return nil, nil
+}
+
+// getHostname calls hostname, or os.Hostname if it's nil.
+func getHostname(hostname func() (string, error)) (string, error) {
+	if hostname == nil {
+		return os.Hostname()
+	}
+	return hostname()
+}
+
+// InPathCheck checks if the given executable is present in $PATH.
+type InPathCheck struct {
+	executable string
+	mandatory  bool
+	exec       utilsexec.Interface
+	label      string
+	suggestion string
+}
+
+// Name returns label for individual InPathCheck. If not known, will return based on path.
+func (ipc InPathCheck) Name() string {
+	if ipc.label != "" {
+		return ipc.label
+	}
+	return fmt.Sprintf("FileExisting-%s", strings.Replace(ipc.executable, "/", "-", -1))
+}
+
+func (ipc InPathCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"executable": ipc.executable,
+		"mandatory":  ipc.mandatory,
+	}
+}
+
+// Check validates if the given executable is present in the path.
+func (ipc InPathCheck) Check() (warnings, errs []error) {
+	klog.V(1).Infof("validating the presence of executable %s", ipc.executable)
+
+	if _, err := ipc.exec.LookPath(ipc.executable); err != nil {
+		msg := fmt.Sprintf("%s not found in system path", ipc.executable)
+		if ipc.suggestion != "" {
+			msg = fmt.Sprintf("%s, %s", msg, ipc.suggestion)
+		}
+		if ipc.mandatory {
+			// Return as an error:
+			return nil, []error{errors.New(msg)}
+		}
+		// Return as a warning:
+		return []error{errors.New(msg)}, nil
+	}
+	return nil, nil
+}
+
+// runInPathChecks runs the InPathChecks and aggregates their results.
+func runInPathChecks(checks []InPathCheck) (warnings, errorList []error) {
+	for _, c := range checks {
+		w, e := c.Check()
+		warnings = append(warnings, w...)
+		errorList = append(errorList, e...)
+	}
+	return warnings, errorList
+}
+
+// NetworkToolsCheck verifies the presence of the network tools needed by kube-proxy,
+// kubectl port-forward and the OpenYurt network components.
+type NetworkToolsCheck struct {
+	exec utilsexec.Interface
+}
+
+func (NetworkToolsCheck) Name() string {
+	return "NetworkTools"
+}
+
+func (ntc NetworkToolsCheck) Check() (warnings, errorList []error) {
+	return runInPathChecks([]InPathCheck{
+		{executable: "ip", mandatory: true, exec: ntc.exec, suggestion: "it is required by kubelet and the CNI plugins to configure pod network, please install iproute2"},
+		{executable: "ebtables", mandatory: true, exec: ntc.exec, suggestion: "it is required by the bridge CNI plugins, please install ebtables"},
+		{executable: "socat", mandatory: false, exec: ntc.exec, suggestion: "kubectl port-forward to pods on this node will not work, please install socat"},
+	})
+}
+
+// MountUtilsCheck verifies the presence of the binaries the mount utilities of kubelet rely on,
+// missing ones cause cryptic volume mount failures.
+type MountUtilsCheck struct {
+	exec utilsexec.Interface
+}
+
+func (MountUtilsCheck) Name() string {
+	return "MountUtils"
+}
+
+func (muc MountUtilsCheck) Check() (warnings, errorList []error) {
+	return runInPathChecks([]InPathCheck{
+		{executable: "mount", mandatory: true, exec: muc.exec, suggestion: "it is required by kubelet to mount volumes, please install util-linux"},
+		{executable: "umount", mandatory: true, exec: muc.exec, suggestion: "it is required by kubelet to unmount volumes, please install util-linux"},
+		{executable: "findmnt", mandatory: true, exec: muc.exec, suggestion: "it is required by kubelet to inspect mount points, please install util-linux"},
+		{executable: "nsenter", mandatory: true, exec: muc.exec, suggestion: "it is required by kubelet to run mount operations in the host namespaces, please install util-linux"},
+	})
+}
+
+const (
+	// maxTimeSyncOffset is the offset above which the clock is considered as still slewing
+	maxTimeSyncOffset = 1.0
+)
+
+// TimeSyncStatusCheck verifies that the time synchronization daemon has actually synchronized
+// the clock, rather than just running. It asks chronyc first and falls back to timedatectl.
+type TimeSyncStatusCheck struct {
+	exec utilsexec.Interface
+}
+
+func (TimeSyncStatusCheck) Name() string {
+	return "TimeSyncStatus"
+}
+
+func (tsc TimeSyncStatusCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infoln("validating time synchronization status")
+
+	if _, err := tsc.exec.LookPath("chronyc"); err == nil {
+		out, err := tsc.exec.Command("chronyc", "tracking").Output()
+		if err != nil {
+			return []error{errors.Wrapf(err, "failed to run chronyc tracking, output: %s", out)}, nil
+		}
+		if err := parseChronyTracking(string(out)); err != nil {
+			return []error{err}, nil
+		}
+		return nil, nil
+	}
+
+	if _, err := tsc.exec.LookPath("timedatectl"); err == nil {
+		out, err := tsc.exec.Command("timedatectl", "show", "-p", "NTPSynchronized", "--value").Output()
+		if err != nil {
+			return []error{errors.Wrapf(err, "failed to run timedatectl, output: %s", out)}, nil
+		}
+		if synced := strings.TrimSpace(string(out)); synced != "yes" {
+			return []error{errors.Errorf("system clock is not synchronized, timedatectl reports NTPSynchronized=%s", synced)}, nil
+		}
+		return nil, nil
+	}
+
+	klog.V(1).Infoln("neither chronyc nor timedatectl is found, skipping time synchronization status check")
+	return nil, nil
+}
+
+// parseChronyTracking returns an error if the output of `chronyc tracking` shows the clock
+// is not synchronized, or is still slewing towards the NTP time.
+func parseChronyTracking(out string) error {
+	for _, line := range strings.Split(out, "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		switch key {
+		case "Leap status":
+			if value == "Not synchronised" {
+				return errors.New("system clock is not synchronized, chronyc reports 'Not synchronised'")
+			}
+		case "System time":
+			// e.g. "0.000012345 seconds fast of NTP time"
+			fields := strings.Fields(value)
+			if len(fields) == 0 {
+				continue
+			}
+			offset, err := strconv.ParseFloat(fields[0], 64)
+			if err == nil && offset > maxTimeSyncOffset {
+				return errors.Errorf("system clock is still slewing, %s", value)
+			}
+		}
+	}
+	return nil
+}
+
+const (
+	dbusSystemBusSocket = "/run/dbus/system_bus_socket"
+	dbusDialTimeout     = 2 * time.Second
+)
+
+// DBusCheck verifies that the system bus is connectable, kubelet and the container runtime
+// talk to systemd over dbus when using the systemd cgroup driver. It's skipped on non-systemd init systems.
+type DBusCheck struct {
+	// SocketPath defaults to /run/dbus/system_bus_socket
+	SocketPath string
+}
+
+func (DBusCheck) Name() string {
+	return "DBus"
+}
+
+func (dc DBusCheck) Config() map[string]interface{} {
+	return map[string]interface{}{"socketPath": dc.SocketPath}
+}
+
+func (dc DBusCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infoln("validating the system bus")
+
+	initSystem, err := detectInitSystem()
+	if err != nil {
+		return []error{err}, nil
+	}
+	if _, ok := initSystem.(*initsystem.SystemdInitSystem); !ok {
+		klog.V(1).Infoln("init system is not systemd, skipping dbus check")
+		return nil, nil
+	}
+
+	socketPath := dc.SocketPath
+	if socketPath == "" {
+		socketPath = dbusSystemBusSocket
+	}
+	if err := checkDBusSocket(socketPath); err != nil {
+		return []error{errors.Wrap(err, "the systemd cgroup driver will not work, please start dbus or use the cgroupfs cgroup driver")}, nil
+	}
+	return nil, nil
+}
+
+// checkDBusSocket returns an error if path is not a unix socket accepting connections.
+func checkDBusSocket(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return errors.Wrapf(err, "dbus socket %s is not found", path)
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return errors.Errorf("dbus socket %s is not a socket", path)
+	}
+	conn, err := net.DialTimeout("unix", path, dbusDialTimeout)
+	if err != nil {
+		return errors.Wrapf(err, "failed to connect to dbus socket %s", path)
+	}
+	conn.Close()
+	return nil
+}
+
+const (
+	urandomDevice = "/dev/urandom"
+	// randomProbeBytes is the number of bytes read from the random device
+	randomProbeBytes = 16
+)
+
+// RandomDeviceCheck verifies that /dev/urandom is a working character device, which is sometimes
+// missing in minimal chroots and containers and breaks all cryptography.
+type RandomDeviceCheck struct{}
+
+func (RandomDeviceCheck) Name() string {
+	return "RandomDevice"
+}
+
+func (RandomDeviceCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infof("validating random device %s", urandomDevice)
+
+	if err := probeRandomDevice(urandomDevice); err != nil {
+		return nil, []error{err}
+	}
+	return nil, nil
+}
+
+// probeRandomDevice returns an error if path is not a character device producing random bytes.
+func probeRandomDevice(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "unable to open random device %s", path)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return errors.Wrapf(err, "unable to stat random device %s", path)
+	}
+	if info.Mode()&os.ModeCharDevice == 0 {
+		return errors.Errorf("random device %s is not a character device", path)
+	}
+	buf := make([]byte, randomProbeBytes)
+	if _, err := io.ReadFull(f, buf); err != nil {
+		return errors.Wrapf(err, "unable to read random device %s", path)
+	}
+	for _, b := range buf {
+		if b != 0 {
+			return nil
+		}
+	}
+	return errors.Errorf("random device %s only produces zeros", path)
+}
+
+// packageManagerLocks are the lock files dpkg holds during a transaction.
+var packageManagerLocks = []string{"/var/lib/dpkg/lock-frontend", "/var/lib/dpkg/lock"}
+
+// packageManagerPidFiles are the pid files yum and dnf write during a transaction.
+var packageManagerPidFiles = []string{"/var/run/yum.pid", "/var/run/dnf.pid"}
+
+// PackageManagerLockCheck warns when a package manager transaction is in progress, installing
+// the prerequisites concurrently leaves them half-installed.
+type PackageManagerLockCheck struct{}
+
+func (PackageManagerLockCheck) Name() string {
+	return "PackageManagerLock"
+}
+
+func (PackageManagerLockCheck) Check() (warnings, errorList []error) {
+	klog.V(1).Infoln("validating no package manager transaction is in progress")
+
+	for _, path := range packageManagerLocks {
+		locked, err := isFileLocked(path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				klog.V(1).Infof("unable to check lock %s: %v", path, err)
+			}
+			continue
+		}
+		if locked {
+			warnings = append(warnings, errors.Errorf("%s is locked, a package operation is in progress, please wait for it to finish", path))
+		}
+	}
+	for _, path := range packageManagerPidFiles {
+		if pid, alive := pidFileAlive(path); alive {
+			warnings = append(warnings, errors.Errorf("process %d in %s is running, a package operation is in progress, please wait for it to finish", pid, path))
+		}
+	}
+	return warnings, nil
+}
+
+// pidFileAlive returns the pid in the pid file at path, and whether the process is running.
+func pidFileAlive(path string) (int, bool) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+	_, err = os.Stat(filepath.Join("/proc", strconv.Itoa(pid)))
+	return pid, err == nil
+}
+
+// ProcSysMountedCheck verifies that procfs and sysfs are mounted at ProcRoot and SysRoot and are
+// populated, e.g. when preflight runs in a container, as most checks silently report bogus results otherwise.
+type ProcSysMountedCheck struct {
+	// ProcRoot defaults to /proc.
+	ProcRoot string
+	// SysRoot defaults to /sys.
+	SysRoot string
+}
+
+func (ProcSysMountedCheck) Name() string {
+	return "ProcSysMounted"
+}
+
+func (psc ProcSysMountedCheck) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"procRoot": psc.ProcRoot,
+		"sysRoot":  psc.SysRoot,
+	}
+}
+
+func (psc ProcSysMountedCheck) Check() (warnings, errorList []error) {
+	procRoot, sysRoot := psc.ProcRoot, psc.SysRoot
+	if procRoot == "" {
+		procRoot = "/proc"
+	}
+	if sysRoot == "" {
+		sysRoot = "/sys"
+	}
+	klog.V(1).Infof("validating procfs is mounted at %s and sysfs at %s", procRoot, sysRoot)
+
+	content, err := os.ReadFile(filepath.Join(procRoot, "mounts"))
+	if err != nil {
+		return nil, []error{errors.Wrapf(err, "procfs is not mounted at %s", procRoot)}
+	}
+	mounts := parseMounts(string(content))
+	for _, expected := range []struct {
+		root, fsType, entry string
+	}{
+		{root: procRoot, fsType: "proc", entry: "self"},
+		{root: sysRoot, fsType: "sysfs", entry: "kernel"},
+	} {
+		if err := validateSpecialMount(mounts, expected.root, expected.fsType); err != nil {
+			errorList = append(errorList, err)
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(expected.root, expected.entry)); err != nil {
+			errorList = append(errorList, errors.Wrapf(err, "%s at %s is not populated", expected.fsType, expected.root))
+		}
+	}
+	return nil, errorList
+}
+
+// validateSpecialMount returns an error if the mount at root in mounts is missing or not of fsType.
+func validateSpecialMount(mounts []mountEntry, root, fsType string) error {
+	var found *mountEntry
+	for i := range mounts {
+		if filepath.Clean(mounts[i].MountPoint) == filepath.Clean(root) {
+			found = &mounts[i]
+		}
+	}
+	if found == nil {
+		return errors.Errorf("%s is not mounted at %s", fsType, root)
+	}
+	if found.FSType != fsType {
+		return errors.Errorf("%s is mounted with filesystem type %s instead of %s", root, found.FSType, fsType)
+	}
+	return nil
+}
@@ -0,0 +1,760 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"io"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	utilsexec "k8s.io/utils/exec"
+	fakeexec "k8s.io/utils/exec/testing"
+
+	"github.com/openyurtio/openyurt/pkg/yurtadm/util/initsystem"
+)
+
+func TestParseBinaryVersion(t *testing.T) {
+	tests := []struct {
+		name      string
+		output    string
+		expected  string
+		expectErr bool
+	}{
+		{
+			name:     "kubelet",
+			output:   "Kubernetes v1.22.3\n",
+			expected: "1.22.3",
+		},
+		{
+			name:     "kubeadm short",
+			output:   "v1.22.3\n",
+			expected: "1.22.3",
+		},
+		{
+			name:     "kubectl version info",
+			output:   `Client Version: version.Info{Major:"1", Minor:"22", GitVersion:"v1.22.3", GitCommit:"c92036820499fedefec0f847e2054d824aea6cd1"}`,
+			expected: "1.22.3",
+		},
+		{
+			name:     "kubectl with kustomize version",
+			output:   "Client Version: v1.28.2\nKustomize Version: v5.0.4-0.20230601165947-6ce0bf390ce3\n",
+			expected: "1.28.2",
+		},
+		{
+			name:      "no version",
+			output:    "command not found",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := parseBinaryVersion(tt.output)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("expected error %v, got %v", tt.expectErr, err)
+			}
+			if err == nil && v.String() != tt.expected {
+				t.Errorf("expected version %s, got %s", tt.expected, v.String())
+			}
+		})
+	}
+}
+
+type fakeInitSystem struct {
+	enabled, active bool
+}
+
+func (fis fakeInitSystem) ServiceIsEnabled(string) bool { return fis.enabled }
+func (fis fakeInitSystem) ServiceEnable(string) error   { return nil }
+func (fis fakeInitSystem) ServiceIsActive(string) bool  { return fis.active }
+
+func TestCheckService(t *testing.T) {
+	tests := []struct {
+		name           string
+		initSystem     fakeInitSystem
+		spec           ServiceSpec
+		expectWarnings int
+		expectErrors   int
+		expectMessage  string
+	}{
+		{
+			name:       "required and running",
+			initSystem: fakeInitSystem{enabled: true, active: true},
+			spec:       ServiceSpec{Name: "kubelet", MustBeActive: true},
+		},
+		{
+			name:           "required but stopped",
+			initSystem:     fakeInitSystem{},
+			spec:           ServiceSpec{Name: "kubelet", MustBeActive: true},
+			expectWarnings: 1,
+			expectErrors:   1,
+		},
+		{
+			name:           "optional and stopped",
+			initSystem:     fakeInitSystem{enabled: true},
+			spec:           ServiceSpec{Name: "chronyd", Label: "time sync"},
+			expectWarnings: 1,
+			expectMessage:  "time sync service is not active",
+		},
+		{
+			name:           "running but not enabled",
+			initSystem:     fakeInitSystem{active: true},
+			spec:           ServiceSpec{Name: "containerd", MustBeActive: true},
+			expectWarnings: 1,
+			expectMessage:  "please run 'systemctl enable containerd.service'",
+		},
+		{
+			name:           "existing kubelet on join",
+			initSystem:     fakeInitSystem{enabled: true, active: true},
+			spec:           ServiceSpec{Name: "kubelet", MustBeActive: true, ConflictIfRunning: true},
+			expectWarnings: 1,
+		},
+		{
+			name:       "no kubelet on join",
+			initSystem: fakeInitSystem{},
+			spec:       ServiceSpec{Name: "kubelet", ConflictIfRunning: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, errs := checkService(tt.initSystem, tt.spec)
+			if len(warnings) != tt.expectWarnings || len(errs) != tt.expectErrors {
+				t.Errorf("expected %d warnings and %d errors, got warnings %v, errors %v", tt.expectWarnings, tt.expectErrors, warnings, errs)
+			}
+			if tt.expectMessage != "" && !strings.Contains(warnings[0].Error(), tt.expectMessage) {
+				t.Errorf("expected warning containing %q, got %v", tt.expectMessage, warnings[0])
+			}
+		})
+	}
+}
+
+func TestCheckInitSystemRunning(t *testing.T) {
+	runtimeDir := t.TempDir()
+	tests := []struct {
+		name       string
+		initSystem initsystem.InitSystem
+		runtimeDir string
+		expectErr  bool
+	}{
+		{name: "systemd running", initSystem: &initsystem.SystemdInitSystem{}, runtimeDir: runtimeDir},
+		{name: "systemctl in container", initSystem: &initsystem.SystemdInitSystem{}, runtimeDir: filepath.Join(runtimeDir, "missing"), expectErr: true},
+		{name: "other init system", initSystem: fakeInitSystem{}, runtimeDir: filepath.Join(runtimeDir, "missing")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkInitSystemRunning(tt.initSystem, tt.runtimeDir)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("expected error %v, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateStaticHostname(t *testing.T) {
+	tests := []struct {
+		name      string
+		hostname  string
+		content   string
+		expectErr bool
+	}{
+		{name: "same", hostname: "edge-1", content: "edge-1\n"},
+		{name: "case differs", hostname: "edge-1", content: "Edge-1\n"},
+		{name: "transient", hostname: "dhcp-10-0-0-8", content: "edge-1\n", expectErr: true},
+		{name: "empty", hostname: "edge-1", content: "", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateStaticHostname(tt.hostname, tt.content)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("expected error %v, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestCheckLogDir(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "messages")
+	if err := os.WriteFile(file, []byte("test"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		dir            string
+		minBytes       uint64
+		expectWarnings int
+		expectErrors   int
+	}{
+		{name: "enough space", dir: dir},
+		{name: "not enough space", dir: dir, minBytes: math.MaxUint64, expectWarnings: 1},
+		{name: "missing", dir: filepath.Join(dir, "missing"), expectErrors: 1},
+		{name: "not a directory", dir: file, expectErrors: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, errs := checkLogDir(tt.dir, tt.minBytes)
+			if len(warnings) != tt.expectWarnings || len(errs) != tt.expectErrors {
+				t.Errorf("expected %d warnings and %d errors, got warnings %v, errors %v", tt.expectWarnings, tt.expectErrors, warnings, errs)
+			}
+		})
+	}
+}
+
+func TestCheckZoneInfoDir(t *testing.T) {
+	complete := t.TempDir()
+	if err := os.WriteFile(filepath.Join(complete, zoneInfoUTC), []byte("TZif2"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		dir       string
+		expectErr bool
+	}{
+		{name: "complete", dir: complete},
+		{name: "utc missing", dir: t.TempDir(), expectErr: true},
+		{name: "database missing", dir: filepath.Join(complete, "missing"), expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkZoneInfoDir(tt.dir)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("expected error %v, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestVirtualizationCheck(t *testing.T) {
+	tests := []struct {
+		virt           string
+		expectWarnings int
+	}{
+		{virt: "kvm"},
+		{virt: "none"},
+		{virt: "openvz", expectWarnings: 1},
+		{virt: "wsl", expectWarnings: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.virt, func(t *testing.T) {
+			fcmd := fakeexec.FakeCmd{OutputScript: []fakeexec.FakeAction{
+				func() ([]byte, []byte, error) { return []byte(tt.virt + "\n"), nil, nil },
+			}}
+			fexec := &fakeexec.FakeExec{
+				LookPathFunc: func(file string) (string, error) { return "/usr/bin/" + file, nil },
+				CommandScript: []fakeexec.FakeCommandAction{
+					func(cmd string, args ...string) utilsexec.Cmd { return fakeexec.InitFakeCmd(&fcmd, cmd, args...) },
+				},
+			}
+			warnings, errs := VirtualizationCheck{exec: fexec}.Check()
+			if len(warnings) != tt.expectWarnings || len(errs) != 0 {
+				t.Errorf("expected %d warnings and no errors, got warnings %v, errors %v", tt.expectWarnings, warnings, errs)
+			}
+		})
+	}
+}
+
+func TestDMIVirtualization(t *testing.T) {
+	tests := []struct {
+		product  string
+		vendor   string
+		expected string
+	}{
+		{product: "KVM", vendor: "Red Hat", expected: "kvm"},
+		{product: "Standard PC (Q35 + ICH9, 2009)", vendor: "QEMU", expected: "qemu"},
+		{product: "VMware Virtual Platform", vendor: "VMware, Inc.", expected: "vmware"},
+		{product: "VirtualBox", vendor: "innotek GmbH", expected: "oracle"},
+		{product: "HVM domU", vendor: "Xen", expected: "xen"},
+		{product: "Virtual Machine", vendor: "Microsoft Corporation", expected: "microsoft"},
+		{product: "c5.large", vendor: "Amazon EC2", expected: "amazon"},
+		{product: "Google Compute Engine", vendor: "Google", expected: "google"},
+		{product: "Bochs", vendor: "Bochs", expected: "bochs"},
+		{product: "Parallels Virtual Platform", vendor: "Parallels Software International Inc.", expected: "parallels"},
+		{product: "Surface Laptop 4", vendor: "Microsoft Corporation", expected: "none"},
+		{product: "PowerEdge R740", vendor: "Dell Inc.", expected: "none"},
+		{expected: "none"},
+	}
+	for _, tt := range tests {
+		if virt := dmiVirtualization(tt.product, tt.vendor); virt != tt.expected {
+			t.Errorf("product %q of vendor %q: expected %q, got %q", tt.product, tt.vendor, tt.expected, virt)
+		}
+	}
+}
+
+func TestLXCNestingEnabled(t *testing.T) {
+	procSys := func(opts ...string) mountEntry {
+		return mountEntry{Device: "proc", MountPoint: "/proc/sys", FSType: "proc", Options: opts}
+	}
+	tests := []struct {
+		name     string
+		label    string
+		mounts   []mountEntry
+		expected bool
+	}{
+		{
+			name:     "nesting apparmor profile",
+			label:    "lxc-container-default-with-nesting (enforce)",
+			mounts:   []mountEntry{procSys("ro", "relatime")},
+			expected: true,
+		},
+		{
+			name:   "read-only /proc/sys",
+			label:  "lxc-container-default-cgns (enforce)",
+			mounts: []mountEntry{procSys("ro", "relatime")},
+		},
+		{
+			name:     "read-only /proc/sys shadowed by a writable one",
+			mounts:   []mountEntry{procSys("ro", "relatime"), procSys("rw", "relatime")},
+			expected: true,
+		},
+		{
+			name:     "no /proc/sys mount",
+			label:    "unconfined",
+			expected: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if enabled := lxcNestingEnabled(tt.label, tt.mounts); enabled != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, enabled)
+			}
+		})
+	}
+}
+
+func TestParseSystemdVersion(t *testing.T) {
+	tests := []struct {
+		name      string
+		output    string
+		expected  int
+		expectErr bool
+	}{
+		{
+			name:     "ubuntu",
+			output:   "systemd 249 (249.11-0ubuntu3.9)\n+PAM +AUDIT +SELINUX +APPARMOR +IMA\n",
+			expected: 249,
+		},
+		{
+			name:     "centos",
+			output:   "systemd 219\n+PAM +AUDIT +SELINUX +IMA -APPARMOR\n",
+			expected: 219,
+		},
+		{
+			name:      "invalid output",
+			output:    "command not found",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, err := parseSystemdVersion(tt.output)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("expected error %v, got %v", tt.expectErr, err)
+			}
+			if version != tt.expected {
+				t.Errorf("expected version %d, got %d", tt.expected, version)
+			}
+		})
+	}
+}
+
+// fakeLookPathExec returns a fake exec on which only the missing executables can't be found.
+func fakeLookPathExec(missing ...string) *fakeexec.FakeExec {
+	return &fakeexec.FakeExec{
+		LookPathFunc: func(file string) (string, error) {
+			for _, m := range missing {
+				if file == m {
+					return "", utilsexec.ErrExecutableNotFound
+				}
+			}
+			return "/usr/bin/" + file, nil
+		},
+	}
+}
+
+func TestNetworkToolsCheck(t *testing.T) {
+	tests := []struct {
+		name           string
+		missing        []string
+		expectWarnings int
+		expectErrors   int
+	}{
+		{name: "all present"},
+		{name: "socat missing", missing: []string{"socat"}, expectWarnings: 1},
+		{name: "ip and ebtables missing", missing: []string{"ip", "ebtables"}, expectErrors: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, errs := NetworkToolsCheck{exec: fakeLookPathExec(tt.missing...)}.Check()
+			if len(warnings) != tt.expectWarnings || len(errs) != tt.expectErrors {
+				t.Errorf("expected %d warnings and %d errors, got warnings %v, errors %v", tt.expectWarnings, tt.expectErrors, warnings, errs)
+			}
+		})
+	}
+}
+
+func TestMountUtilsCheck(t *testing.T) {
+	tests := []struct {
+		name         string
+		missing      []string
+		expectErrors int
+	}{
+		{name: "all present"},
+		{name: "findmnt missing", missing: []string{"findmnt"}, expectErrors: 1},
+		{name: "util-linux missing", missing: []string{"mount", "umount", "findmnt", "nsenter"}, expectErrors: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, errs := MountUtilsCheck{exec: fakeLookPathExec(tt.missing...)}.Check()
+			if len(warnings) != 0 || len(errs) != tt.expectErrors {
+				t.Errorf("expected no warnings and %d errors, got warnings %v, errors %v", tt.expectErrors, warnings, errs)
+			}
+		})
+	}
+}
+
+func TestParseChronyTracking(t *testing.T) {
+	tests := []struct {
+		name      string
+		output    string
+		expectErr bool
+	}{
+		{
+			name: "synchronized",
+			output: `Reference ID    : A9FEA97B (169.254.169.123)
+Stratum         : 4
+System time     : 0.000012345 seconds fast of NTP time
+Leap status     : Normal
+`,
+			expectErr: false,
+		},
+		{
+			name: "not synchronized",
+			output: `Reference ID    : 00000000 ()
+Stratum         : 0
+System time     : 0.000000000 seconds fast of NTP time
+Leap status     : Not synchronised
+`,
+			expectErr: true,
+		},
+		{
+			name: "slewing",
+			output: `Reference ID    : A9FEA97B (169.254.169.123)
+System time     : 12.500000000 seconds slow of NTP time
+Leap status     : Normal
+`,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := parseChronyTracking(tt.output); (err != nil) != tt.expectErr {
+				t.Errorf("expected error %v, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestParseTmpfsSize(t *testing.T) {
+	tests := []struct {
+		opt      string
+		expected uint64
+		ok       bool
+	}{
+		{opt: "size=65536k", expected: 64 << 20, ok: true},
+		{opt: "size=1g", expected: 1 << 30, ok: true},
+		{opt: "size=1048576", expected: 1 << 20, ok: true},
+		{opt: "size=50%", ok: false},
+		{opt: "mode=1777", ok: false},
+	}
+
+	for _, tt := range tests {
+		size, ok := parseTmpfsSize(tt.opt)
+		if ok != tt.ok || size != tt.expected {
+			t.Errorf("parseTmpfsSize(%q) expected (%d, %v), got (%d, %v)", tt.opt, tt.expected, tt.ok, size, ok)
+		}
+	}
+}
+
+func TestFindMount(t *testing.T) {
+	mounts := parseMounts(`rootfs / rootfs rw 0 0
+/dev/sda1 / ext4 ro,relatime 0 0
+/dev/sda2 /var ext4 rw,relatime 0 0
+tmpfs /var/run tmpfs rw 0 0
+`)
+	tests := []struct {
+		path       string
+		mountPoint string
+		readOnly   bool
+	}{
+		{path: "/", mountPoint: "/", readOnly: true},
+		{path: "/usr/local/bin", mountPoint: "/", readOnly: true},
+		{path: "/var/lib/kubelet", mountPoint: "/var"},
+		{path: "/var/run/yurthub", mountPoint: "/var/run"},
+		{path: "/variable", mountPoint: "/", readOnly: true},
+	}
+	for _, tt := range tests {
+		m := findMount(tt.path, mounts)
+		if m == nil || m.MountPoint != tt.mountPoint || hasMountOption(m, "ro") != tt.readOnly {
+			t.Errorf("findMount(%q) expected mount point %s (read-only %v), got %+v", tt.path, tt.mountPoint, tt.readOnly, m)
+		}
+	}
+}
+
+func TestPathsOnRootFS(t *testing.T) {
+	mounts := parseMounts(`/dev/mmcblk0p2 / ext4 rw,relatime 0 0
+/dev/sda1 /var/lib/containerd ext4 rw,relatime 0 0
+`)
+	onRoot := pathsOnRootFS([]string{"/var/lib/containerd", "/var/lib/kubelet"}, mounts)
+	if !reflect.DeepEqual(onRoot, []string{"/var/lib/kubelet"}) {
+		t.Errorf("expected only /var/lib/kubelet on root filesystem, got %v", onRoot)
+	}
+}
+
+func TestFindMountInfo(t *testing.T) {
+	entries := parseMountInfo(`22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+30 22 8:2 / /var/lib/kubelet rw,relatime - ext4 /dev/sda2 rw
+31 22 0:26 / /run rw,nosuid master:5 - tmpfs tmpfs rw
+`)
+	tests := []struct {
+		path       string
+		mountPoint string
+		shared     bool
+	}{
+		{path: "/etc/kubernetes", mountPoint: "/", shared: true},
+		{path: "/var/lib/kubelet/pods", mountPoint: "/var/lib/kubelet"},
+		{path: "/run/containerd", mountPoint: "/run"},
+	}
+	for _, tt := range tests {
+		m := findMountInfo(tt.path, entries)
+		if m == nil || m.MountPoint != tt.mountPoint || m.Shared != tt.shared {
+			t.Errorf("findMountInfo(%q) expected mount point %s (shared %v), got %+v", tt.path, tt.mountPoint, tt.shared, m)
+		}
+	}
+}
+
+func TestValidateSpecialMount(t *testing.T) {
+	mounts := parseMounts(`proc /proc proc rw,nosuid,nodev,noexec,relatime 0 0
+tmpfs /sys tmpfs rw 0 0
+/dev/sda1 /host/proc ext4 rw 0 0
+`)
+	tests := []struct {
+		root, fsType string
+		expectErr    bool
+	}{
+		{root: "/proc", fsType: "proc"},
+		{root: "/sys", fsType: "sysfs", expectErr: true},
+		{root: "/host/proc", fsType: "proc", expectErr: true},
+		{root: "/host/sys", fsType: "sysfs", expectErr: true},
+	}
+	for _, tt := range tests {
+		if err := validateSpecialMount(mounts, tt.root, tt.fsType); (err != nil) != tt.expectErr {
+			t.Errorf("validateSpecialMount(%s, %s) expected error %v, got %v", tt.root, tt.fsType, tt.expectErr, err)
+		}
+	}
+}
+
+func TestParseSwaps(t *testing.T) {
+	content := `Filename				Type		Size		Used		Priority
+/dev/sda2                               partition	2097148		1048576		-2
+/swap\040file                           file		1048576		0		-3
+`
+	expected := []swapEntry{
+		{Filename: "/dev/sda2", Size: 2097148, Used: 1048576},
+		{Filename: "/swap file", Size: 1048576, Used: 0},
+	}
+	if swaps := parseSwaps(content); !reflect.DeepEqual(swaps, expected) {
+		t.Errorf("expected swaps %v, got %v", expected, swaps)
+	}
+	if swaps := parseSwaps("Filename\tType\tSize\tUsed\tPriority\n"); len(swaps) != 0 {
+		t.Errorf("expected no swap, got %v", swaps)
+	}
+}
+
+func TestEffectiveLocale(t *testing.T) {
+	tests := []struct {
+		env          map[string]string
+		expectedName string
+		expected     string
+	}{
+		{env: map[string]string{}, expectedName: "LANG", expected: ""},
+		{env: map[string]string{"LANG": "en_US.UTF-8"}, expectedName: "LANG", expected: "en_US.UTF-8"},
+		{env: map[string]string{"LANG": "en_US.UTF-8", "LC_CTYPE": "C"}, expectedName: "LC_CTYPE", expected: "C"},
+		{env: map[string]string{"LANG": "C", "LC_CTYPE": "C", "LC_ALL": "C.UTF-8"}, expectedName: "LC_ALL", expected: "C.UTF-8"},
+	}
+	for _, tt := range tests {
+		name, locale := effectiveLocale(func(key string) string { return tt.env[key] })
+		if name != tt.expectedName || locale != tt.expected {
+			t.Errorf("env %v: expected %s=%s, got %s=%s", tt.env, tt.expectedName, tt.expected, name, locale)
+		}
+	}
+}
+
+func TestCheckDBusSocket(t *testing.T) {
+	dir := t.TempDir()
+	listening := filepath.Join(dir, "listening.sock")
+	l, err := net.Listen("unix", listening)
+	if err != nil {
+		t.Fatalf("failed to listen on %s: %v", listening, err)
+	}
+	defer l.Close()
+
+	regular := filepath.Join(dir, "regular")
+	if err := os.WriteFile(regular, nil, 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", regular, err)
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		expectErr bool
+	}{
+		{
+			name: "listening socket",
+			path: listening,
+		},
+		{
+			name:      "missing socket",
+			path:      filepath.Join(dir, "missing.sock"),
+			expectErr: true,
+		},
+		{
+			name:      "not a socket",
+			path:      regular,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkDBusSocket(tt.path)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("expected error %v, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestHostnameUnchangedCheck(t *testing.T) {
+	tests := []struct {
+		name           string
+		before, after  string
+		expectWarnings int
+	}{
+		{
+			name:   "unchanged",
+			before: "edge-1",
+			after:  "edge-1",
+		},
+		{
+			name:           "changed by dhcp",
+			before:         "edge-1",
+			after:          "dhcp-10-0-0-8",
+			expectWarnings: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captured := &CapturedHostname{}
+			capture := HostnameCheck{Captured: captured, hostname: func() (string, error) { return tt.before, nil }}
+			recheck := HostnameUnchangedCheck{Captured: captured, hostname: func() (string, error) { return tt.after, nil }}
+
+			if err := RunChecksWithDependencies([]Checker{recheck, capture}, io.Discard, nil, nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if captured.Hostname() != tt.before {
+				t.Errorf("expected captured hostname %s, got %s", tt.before, captured.Hostname())
+			}
+			if warnings, errs := recheck.Check(); len(warnings) != tt.expectWarnings || len(errs) != 0 {
+				t.Errorf("expected %d warnings and no errors, got warnings %v, errors %v", tt.expectWarnings, warnings, errs)
+			}
+		})
+	}
+}
+
+func TestProbeRandomDevice(t *testing.T) {
+	regular := filepath.Join(t.TempDir(), "urandom")
+	if err := os.WriteFile(regular, make([]byte, 32), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", regular, err)
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		expectErr bool
+	}{
+		{name: "urandom", path: "/dev/urandom"},
+		{name: "zero device", path: "/dev/zero", expectErr: true},
+		{name: "regular file", path: regular, expectErr: true},
+		{name: "missing", path: filepath.Join(t.TempDir(), "missing"), expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := probeRandomDevice(tt.path); (err != nil) != tt.expectErr {
+				t.Errorf("expected error %v, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestPidFileAlive(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		expectAlive bool
+	}{
+		{name: "running process", path: write("running.pid", strconv.Itoa(os.Getpid())+"\n"), expectAlive: true},
+		{name: "stale pid", path: write("stale.pid", "2147483647")},
+		{name: "garbage", path: write("garbage.pid", "yum")},
+		{name: "missing", path: filepath.Join(dir, "missing.pid")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, alive := pidFileAlive(tt.path); alive != tt.expectAlive {
+				t.Errorf("expected alive %v, got %v", tt.expectAlive, alive)
+			}
+		})
+	}
+}
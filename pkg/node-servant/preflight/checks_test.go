@@ -0,0 +1,358 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openyurtio/openyurt/pkg/node-servant/components"
+)
+
+type fakeChecker struct {
+	name     string
+	warnings []error
+	errs     []error
+	called   *int
+}
+
+func (fc fakeChecker) Name() string {
+	return fc.name
+}
+
+func (fc fakeChecker) Check() (warnings, errorList []error) {
+	if fc.called != nil {
+		*fc.called++
+	}
+	return fc.warnings, fc.errs
+}
+
+func TestRunChecksWithPostChecks(t *testing.T) {
+	tests := []struct {
+		name          string
+		checks        []Checker
+		ignore        sets.String
+		expectErr     bool
+		expectPostRun bool
+	}{
+		{
+			name:          "main checks pass",
+			checks:        []Checker{fakeChecker{name: "Pass"}},
+			expectErr:     false,
+			expectPostRun: true,
+		},
+		{
+			name:          "main checks fail",
+			checks:        []Checker{fakeChecker{name: "Fail", errs: []error{errors.New("failed")}}},
+			expectErr:     true,
+			expectPostRun: false,
+		},
+		{
+			name:          "main check failure ignored",
+			checks:        []Checker{fakeChecker{name: "Fail", errs: []error{errors.New("failed")}}},
+			ignore:        sets.NewString("fail"),
+			expectErr:     false,
+			expectPostRun: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := 0
+			post := []Checker{fakeChecker{name: "Post", called: &called}}
+			err := RunChecksWithPostChecks(tt.checks, post, &bytes.Buffer{}, tt.ignore)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("expected error %v, got %v", tt.expectErr, err)
+			}
+			if (called == 1) != tt.expectPostRun {
+				t.Errorf("expected post check run %v, but it ran %d times", tt.expectPostRun, called)
+			}
+		})
+	}
+}
+
+// TestChecksConcurrentSafety runs the read-only checks in parallel, so that
+// `go test -race` can catch checks that share global state or buffers.
+// checkOutcome flattens the results of a check so that runs can be compared.
+func checkOutcome(c Checker) string {
+	warnings, errs := c.Check()
+	return fmt.Sprintf("%s warnings %v errors %v", c.Name(), warnings, errs)
+}
+
+func TestChecksConcurrentSafety(t *testing.T) {
+	// the checks run by RunConvertNodeChecks, so that new checks of the profiles are covered. The
+	// port checks bind the ports and conflict with each other when run concurrently, so only the
+	// checks without side effects are run.
+	root, err := NewChecks(CheckConfig{Profile: ProfileRoot})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	convert, err := NewChecks(CheckConfig{KubePaths: fakeKubePaths{}, DeployTunnel: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var checks []Checker
+	for _, c := range append(root, convert...) {
+		if _, ok := c.(PortOpenCheck); !ok {
+			checks = append(checks, c)
+		}
+	}
+	if len(checks) == 0 {
+		t.Fatalf("expected checks without side effects")
+	}
+
+	expected := make([]string, len(checks))
+	for i, c := range checks {
+		expected[i] = checkOutcome(c)
+	}
+
+	var wg sync.WaitGroup
+	for n := 0; n < 4; n++ {
+		for i, c := range checks {
+			wg.Add(1)
+			go func(i int, c Checker) {
+				defer wg.Done()
+				if outcome := checkOutcome(c); outcome != expected[i] {
+					t.Errorf("expected the concurrent run to report %q, got %q", expected[i], outcome)
+				}
+			}(i, c)
+		}
+	}
+	wg.Wait()
+
+	expectedErr := RunChecks(checks, &bytes.Buffer{}, nil)
+	for n := 0; n < 4; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := RunChecks(checks, &bytes.Buffer{}, nil)
+			if fmt.Sprint(err) != fmt.Sprint(expectedErr) {
+				t.Errorf("expected the concurrent run to return %v, got %v", expectedErr, err)
+			}
+			if err := RunChecks(checks, &bytes.Buffer{}, sets.NewString("all")); err != nil {
+				t.Errorf("expected all errors to be ignored, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestParseMounts(t *testing.T) {
+	content := `sysfs /sys sysfs rw,nosuid,nodev,noexec,relatime 0 0
+/dev/sda1 / ext4 rw,relatime 0 0
+tmpfs /var/lib/kubelet/pods/1234/volumes/kubernetes.io~projected/kube-api-access tmpfs rw,relatime,size=65536k 0 0
+/dev/sdb1 /mnt/with\040space ext4 ro,relatime 0 0
+`
+	expected := []mountEntry{
+		{Device: "sysfs", MountPoint: "/sys", FSType: "sysfs", Options: []string{"rw", "nosuid", "nodev", "noexec", "relatime"}},
+		{Device: "/dev/sda1", MountPoint: "/", FSType: "ext4", Options: []string{"rw", "relatime"}},
+		{Device: "tmpfs", MountPoint: "/var/lib/kubelet/pods/1234/volumes/kubernetes.io~projected/kube-api-access", FSType: "tmpfs", Options: []string{"rw", "relatime", "size=65536k"}},
+		{Device: "/dev/sdb1", MountPoint: "/mnt/with space", FSType: "ext4", Options: []string{"ro", "relatime"}},
+	}
+	if mounts := parseMounts(content); !reflect.DeepEqual(mounts, expected) {
+		t.Errorf("expected mounts %v, got %v", expected, mounts)
+	}
+}
+
+func TestIsSubPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		dir      string
+		expected bool
+	}{
+		{path: "/var/lib/kubelet", dir: "/var/lib/kubelet", expected: true},
+		{path: "/var/lib/kubelet/pods/1234", dir: "/var/lib/kubelet/", expected: true},
+		{path: "/var/lib/kubelet-plugins", dir: "/var/lib/kubelet", expected: false},
+		{path: "/var/lib", dir: "/var/lib/kubelet", expected: false},
+		{path: "/var/lib", dir: "/", expected: true},
+	}
+	for _, tt := range tests {
+		if got := isSubPath(tt.path, tt.dir); got != tt.expected {
+			t.Errorf("isSubPath(%q, %q) expected %v, got %v", tt.path, tt.dir, tt.expected, got)
+		}
+	}
+}
+
+func TestRunChecksReasonCodes(t *testing.T) {
+	checks := []Checker{
+		fakeChecker{name: "Coded", errs: []error{
+			newCheckError(ReasonPortInUse, "Port %d is in use", 10267),
+			errors.New("plain failure"),
+		}},
+		fakeChecker{name: "Plain", errs: []error{errors.New("plain failure")}},
+	}
+
+	err := RunChecks(checks, &bytes.Buffer{}, nil)
+	var preflightErr *Error
+	if !errors.As(err, &preflightErr) {
+		t.Fatalf("expected a preflight error, got %v", err)
+	}
+	if len(preflightErr.Failures) != 2 {
+		t.Fatalf("expected 2 failures, got %d", len(preflightErr.Failures))
+	}
+	if codes := preflightErr.Failures[0].Codes; !reflect.DeepEqual(codes, []string{ReasonPortInUse}) {
+		t.Errorf("expected codes %v, got %v", []string{ReasonPortInUse}, codes)
+	}
+	if codes := preflightErr.Failures[1].Codes; len(codes) != 0 {
+		t.Errorf("expected no codes, got %v", codes)
+	}
+	if !bytes.Contains([]byte(preflightErr.Msg), []byte("\t[ERROR Coded]: Port 10267 is in use\n")) {
+		t.Errorf("expected plain-text message to be unchanged, got %q", preflightErr.Msg)
+	}
+}
+
+func TestRunChecksProgress(t *testing.T) {
+	type call struct {
+		completed, total int
+		name             string
+	}
+	var calls []call
+	checks := []Checker{fakeChecker{name: "First"}, fakeChecker{name: "Second", errs: []error{errors.New("failed")}}}
+
+	RunChecksWithProgress(checks, &bytes.Buffer{}, nil, func(completed, total int, currentName string) {
+		calls = append(calls, call{completed, total, currentName})
+	})
+	expected := []call{{0, 2, "First"}, {1, 2, "First"}, {1, 2, "Second"}, {2, 2, "Second"}}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected progress calls %v, got %v", expected, calls)
+	}
+}
+
+func TestRunChecksDedupe(t *testing.T) {
+	var first, duplicate, other int
+	checks := []Checker{
+		fakeChecker{name: "Port-10250", errs: []error{errors.New("Port 10250 is in use")}, called: &first},
+		fakeChecker{name: "Other", called: &other},
+		fakeChecker{name: "Port-10250", errs: []error{errors.New("Port 10250 is in use")}, called: &duplicate},
+	}
+
+	err := RunChecks(checks, &bytes.Buffer{}, nil)
+	if first != 1 || duplicate != 0 || other != 1 {
+		t.Errorf("expected the first occurrence to run once and the duplicate never, got first %d, duplicate %d, other %d", first, duplicate, other)
+	}
+	var preflightErr *Error
+	if !errors.As(err, &preflightErr) || len(preflightErr.Failures) != 1 {
+		t.Errorf("expected a single failure, got %v", err)
+	}
+}
+
+type fakeDependentChecker struct {
+	fakeChecker
+	deps []string
+}
+
+func (fdc fakeDependentChecker) DependsOn() []string {
+	return fdc.deps
+}
+
+// notReadyRuntime reports the container runtime as not ready, other methods must not be called.
+type notReadyRuntime struct {
+	components.ContainerRuntimeForImage
+}
+
+func (notReadyRuntime) Status() ([]components.RuntimeCondition, error) {
+	return []components.RuntimeCondition{{Type: components.RuntimeReady, Status: false, Reason: "ContainerdNotRunning"}}, nil
+}
+
+func TestRunChecksWithDependencies(t *testing.T) {
+	runtime := notReadyRuntime{}
+	checks := []Checker{
+		CgroupDriverCheck{runtime: runtime, Expected: "systemd"},
+		RuntimeHandlerCheck{runtime: runtime, Handlers: []string{"runc"}},
+		DefaultRuntimeHandlerCheck{runtime: runtime},
+		ContainerdCRIPluginCheck{runtime: runtime},
+		CRIStatusCheck{runtime: runtime},
+		CRIVersionCheck{runtime: runtime},
+	}
+
+	var out bytes.Buffer
+	err := RunChecksWithDependencies(checks, &out, nil, nil)
+	var preflightErr *Error
+	if !errors.As(err, &preflightErr) || len(preflightErr.Failures) != 1 || preflightErr.Failures[0].Name != "CRIStatus" {
+		t.Fatalf("expected only CRIStatus to fail, got %v", err)
+	}
+	for _, name := range []string{"CgroupDriver", "RuntimeHandler", "DefaultRuntimeHandler", "ContainerdCRIPlugin", "CRIVersion"} {
+		expected := "\t[WARNING " + name + "]: skipped (dependency CRIStatus failed)\n"
+		if !bytes.Contains(out.Bytes(), []byte(expected)) {
+			t.Errorf("expected output to contain %q, got %q", expected, out.String())
+		}
+	}
+}
+
+func TestRunChecksWithTransitiveDependencies(t *testing.T) {
+	statusCalled, driverCalled, handlerCalled := 0, 0, 0
+	checks := []Checker{
+		fakeDependentChecker{fakeChecker: fakeChecker{name: "CgroupDriver", called: &driverCalled}, deps: []string{"CRIStatus"}},
+		fakeDependentChecker{fakeChecker: fakeChecker{name: "RuntimeHandler", called: &handlerCalled}, deps: []string{"CgroupDriver", "Missing"}},
+		fakeChecker{name: "CRIStatus", errs: []error{errors.New("container runtime is not ready")}, called: &statusCalled},
+	}
+
+	var out bytes.Buffer
+	err := RunChecksWithDependencies(checks, &out, nil, nil)
+	var preflightErr *Error
+	if !errors.As(err, &preflightErr) || len(preflightErr.Failures) != 1 || preflightErr.Failures[0].Name != "CRIStatus" {
+		t.Fatalf("expected only CRIStatus to fail, got %v", err)
+	}
+	if statusCalled != 1 || driverCalled != 0 || handlerCalled != 0 {
+		t.Errorf("expected only CRIStatus to run, got status %d, driver %d, handler %d", statusCalled, driverCalled, handlerCalled)
+	}
+	expected := "\t[WARNING RuntimeHandler]: skipped (dependency CgroupDriver failed)\n"
+	if !bytes.Contains(out.Bytes(), []byte(expected)) {
+		t.Errorf("expected output to contain %q, got %q", expected, out.String())
+	}
+
+	// without dependencies enabled, everything runs
+	if err := RunChecks(checks, &bytes.Buffer{}, nil); err == nil {
+		t.Errorf("expected error")
+	}
+	if driverCalled != 1 || handlerCalled != 1 {
+		t.Errorf("expected RunChecks to run every check, got driver %d, handler %d", driverCalled, handlerCalled)
+	}
+}
+
+func TestSortChecksByDependencies(t *testing.T) {
+	checks := []Checker{
+		fakeDependentChecker{fakeChecker: fakeChecker{name: "C"}, deps: []string{"B"}},
+		fakeChecker{name: "A"},
+		fakeDependentChecker{fakeChecker: fakeChecker{name: "B"}, deps: []string{"A"}},
+	}
+	ordered, err := sortChecksByDependencies(checks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var names []string
+	for _, c := range ordered {
+		names = append(names, c.Name())
+	}
+	if expected := []string{"A", "B", "C"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected order %v, got %v", expected, names)
+	}
+
+	cyclic := []Checker{
+		fakeDependentChecker{fakeChecker: fakeChecker{name: "A"}, deps: []string{"B"}},
+		fakeDependentChecker{fakeChecker: fakeChecker{name: "B"}, deps: []string{"A"}},
+	}
+	if _, err := sortChecksByDependencies(cyclic); err == nil {
+		t.Errorf("expected error for cyclic dependencies")
+	}
+}
@@ -0,0 +1,311 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"net"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/openyurtio/openyurt/pkg/node-servant/components"
+)
+
+// Profile selects the group of checks materialized by NewChecks.
+type Profile string
+
+const (
+	// ProfileRoot only checks that the user is privileged, it's run before the other profiles to fail fast.
+	ProfileRoot Profile = "root"
+	// ProfileConvert checks a node of an existing cluster before converting it to an edge node.
+	ProfileConvert Profile = "convert"
+	// ProfileJoin checks a fresh node before joining it to the cluster.
+	ProfileJoin Profile = "join"
+	// ProfileImages only pulls Images with Runtime.
+	ProfileImages Profile = "images"
+)
+
+// Severity is the severity the errors of a check are reported with.
+type Severity string
+
+const (
+	// SeverityError keeps the errors of a check fatal, it's the default.
+	SeverityError Severity = "error"
+	// SeverityWarning reports the errors of a check as warnings.
+	SeverityWarning Severity = "warning"
+)
+
+// CheckConfig declares the checks to run, it's materialized by NewChecks.
+type CheckConfig struct {
+	// Profile defaults to ProfileConvert.
+	Profile Profile
+	// Ports must not be in use, defaults to the ports of YurtHub.
+	Ports []int
+	// RequiredFiles must exist on the node.
+	RequiredFiles []string
+	// Services enables checking the state of the services in the init system. ProfileJoin
+	// adds a running kubelet as a conflict, unless kubelet is among them.
+	Services []ServiceSpec
+	// KubePaths enables checking the kubeadm config and flags env files of the convert profile.
+	KubePaths KubePathOperator
+	// DeployTunnel adds the port of yurt-tunnel-agent to Ports.
+	DeployTunnel bool
+
+	// Runtime enables the container runtime checks and the image pull, it's required by Images.
+	Runtime components.ContainerRuntimeForImage
+	// Images are pulled according to ImagePullPolicy, which defaults to IfNotPresent.
+	Images          []string
+	ImagePullPolicy v1.PullPolicy
+	// SandboxImage is the sandbox image among Images, its pull failure is reported distinctly.
+	SandboxImage string
+
+	// NodeName and Client enable the checks against the cluster, both are required.
+	NodeName string
+	Client   kubernetes.Interface
+
+	// ClusterCIDRs are the pod or service CIDRs of the cluster, at most one per ip family.
+	// The ip families of the node are checked against them.
+	ClusterCIDRs []string
+	// NodeCIDRMaskSize enables PodCIDRCapacityCheck when it's set. It's the mask size of the IPv6
+	// per-node pod CIDR when ClusterCIDRs are IPv6 only, and of the IPv4 one otherwise.
+	NodeCIDRMaskSize int
+
+	// Hostname enables capturing the hostname at the start of the run into it,
+	// and warning at the end of the run when the hostname has changed since.
+	Hostname *CapturedHostname
+
+	// Severities overrides the severity of checks by name.
+	Severities map[string]Severity
+}
+
+// NewChecks validates cfg and materializes the ordered checks it declares. The privileged user
+// is only checked by ProfileRoot, which is expected to be run before the other profiles.
+func NewChecks(cfg CheckConfig) ([]Checker, error) {
+	profile := cfg.Profile
+	if profile == "" {
+		profile = ProfileConvert
+	}
+	if profile != ProfileRoot && profile != ProfileConvert && profile != ProfileJoin && profile != ProfileImages {
+		return nil, errors.Errorf("unknown preflight profile %q", profile)
+	}
+
+	ports := cfg.Ports
+	if ports == nil {
+		ports = []int{YurtHubProxySecurePort, YurtHubProxyPort, YurtHubPort}
+	}
+	if cfg.DeployTunnel {
+		ports = append(ports, YurttunnelAgentPort)
+	}
+	for _, port := range ports {
+		if port <= 0 || port > 65535 {
+			return nil, errors.Errorf("invalid port %d", port)
+		}
+	}
+	for _, file := range cfg.RequiredFiles {
+		if !filepath.IsAbs(file) {
+			return nil, errors.Errorf("required file %q is not an absolute path", file)
+		}
+	}
+	services := cfg.Services
+	hasKubelet := false
+	for _, service := range services {
+		if service.Name == "" {
+			return nil, errors.New("service name must not be empty")
+		}
+		hasKubelet = hasKubelet || service.Name == "kubelet"
+	}
+	if profile == ProfileJoin && !hasKubelet {
+		services = append([]ServiceSpec{{Name: "kubelet", ConflictIfRunning: true}}, services...)
+	}
+	if (len(cfg.Images) != 0 || profile == ProfileImages) && cfg.Runtime == nil {
+		return nil, errors.New("a container runtime is required to pull images")
+	}
+	pullPolicy := cfg.ImagePullPolicy
+	if pullPolicy == "" {
+		pullPolicy = v1.PullIfNotPresent
+	}
+	if pullPolicy != v1.PullAlways && pullPolicy != v1.PullIfNotPresent && pullPolicy != v1.PullNever {
+		return nil, errors.Errorf("unsupported image pull policy %q", pullPolicy)
+	}
+	if (cfg.NodeName == "") != (cfg.Client == nil) {
+		return nil, errors.New("node name and client must be set together")
+	}
+	family, err := clusterIPFamily(cfg.ClusterCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	maxMaskSize := 32
+	if family == IPFamilyIPv6 {
+		maxMaskSize = 128
+	}
+	if cfg.NodeCIDRMaskSize < 0 || cfg.NodeCIDRMaskSize > maxMaskSize {
+		return nil, errors.Errorf("invalid node cidr mask size %d", cfg.NodeCIDRMaskSize)
+	}
+
+	switch profile {
+	case ProfileRoot:
+		return applySeverities([]Checker{IsPrivilegedUserCheck{}}, cfg.Severities)
+	case ProfileImages:
+		return applySeverities([]Checker{
+			ImagePullCheck{runtime: cfg.Runtime, imageList: cfg.Images, imagePullPolicy: pullPolicy, sandboxImage: cfg.SandboxImage},
+		}, cfg.Severities)
+	}
+
+	var checks []Checker
+	if cfg.Hostname != nil {
+		checks = append(checks, HostnameCheck{Captured: cfg.Hostname})
+	}
+	switch profile {
+	case ProfileConvert:
+		if cfg.KubePaths != nil {
+			checks = append(checks,
+				FileAtLeastOneExistingCheck{Paths: cfg.KubePaths.GetKubeadmConfPaths(), Label: "KubeadmConfig"},
+				FileExistingCheck{Path: cfg.KubePaths.GetKubeAdmFlagsEnvFile(), Label: "KubeAdmFlagsEnv"},
+			)
+		}
+		checks = append(checks,
+			DirExistingCheck{Path: KubernetesDir},
+			DirExistingCheck{Path: KubeletPkiDir},
+		)
+	case ProfileJoin:
+		checks = append(checks,
+			LeftoverStateCheck{},
+			StaleKubeletMountsCheck{},
+			SwapCheck{},
+		)
+	}
+	for _, file := range cfg.RequiredFiles {
+		checks = append(checks, FileExistingCheck{Path: file})
+	}
+	if len(services) != 0 {
+		checks = append(checks, RequiredServicesCheck{Services: services})
+	}
+	for _, port := range ports {
+		checks = append(checks, PortOpenCheck{port: port})
+	}
+	if family != "" {
+		checks = append(checks,
+			IPFamilyCheck{ClusterFamily: family},
+			IPForwardCheck{IPv4: family != IPFamilyIPv6, IPv6: family != IPFamilyIPv4},
+		)
+	}
+	if cfg.NodeCIDRMaskSize != 0 {
+		checks = append(checks, PodCIDRCapacityCheck{NodeCIDRMaskSize: cfg.NodeCIDRMaskSize, IPv6: family == IPFamilyIPv6})
+	}
+	if cfg.Runtime != nil {
+		checks = append(checks,
+			CRIStatusCheck{runtime: cfg.Runtime},
+			CRIVersionCheck{runtime: cfg.Runtime},
+		)
+		if len(cfg.Images) != 0 {
+			checks = append(checks, ImagePullCheck{runtime: cfg.Runtime, imageList: cfg.Images, imagePullPolicy: pullPolicy, sandboxImage: cfg.SandboxImage})
+		}
+	}
+	if cfg.Client != nil {
+		checks = append(checks, HostnameServiceCollisionCheck{client: cfg.Client, NodeName: cfg.NodeName})
+	}
+	if cfg.Hostname != nil {
+		checks = append(checks, HostnameUnchangedCheck{Captured: cfg.Hostname})
+	}
+
+	return applySeverities(checks, cfg.Severities)
+}
+
+// clusterIPFamily validates cidrs and returns the ip family they make up, or an empty string if there is none.
+func clusterIPFamily(cidrs []string) (string, error) {
+	families := sets.NewString()
+	for _, cidr := range cidrs {
+		ip, _, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid cluster cidr %q", cidr)
+		}
+		family := IPFamilyIPv6
+		if ip.To4() != nil {
+			family = IPFamilyIPv4
+		}
+		if families.Has(family) {
+			return "", errors.Errorf("more than one %s cluster cidr in %v", family, cidrs)
+		}
+		families.Insert(family)
+	}
+	switch families.Len() {
+	case 0:
+		return "", nil
+	case 1:
+		return families.List()[0], nil
+	default:
+		return IPFamilyDualStack, nil
+	}
+}
+
+// applySeverities wraps the checks whose severity is overridden to SeverityWarning.
+// An error is returned for unknown severities and names that don't match any check.
+func applySeverities(checks []Checker, severities map[string]Severity) ([]Checker, error) {
+	names := sets.NewString()
+	for _, c := range checks {
+		names.Insert(c.Name())
+	}
+	for name, severity := range severities {
+		if severity != SeverityError && severity != SeverityWarning {
+			return nil, errors.Errorf("unknown severity %q of check %s", severity, name)
+		}
+		if !names.Has(name) {
+			return nil, errors.Errorf("severity is set for unknown check %s", name)
+		}
+	}
+	for i, c := range checks {
+		if severities[c.Name()] == SeverityWarning {
+			checks[i] = warningChecker{Checker: c}
+		}
+	}
+	return checks, nil
+}
+
+// warningChecker reports the errors of the wrapped check as warnings. The optional interfaces
+// of the wrapped check (DependentChecker, EvidenceChecker and ConfigurableChecker) are passed through.
+type warningChecker struct {
+	Checker
+}
+
+func (wc warningChecker) Check() (warnings, errorList []error) {
+	warnings, errorList = wc.Checker.Check()
+	return append(warnings, errorList...), nil
+}
+
+func (wc warningChecker) DependsOn() []string {
+	if dc, ok := wc.Checker.(DependentChecker); ok {
+		return dc.DependsOn()
+	}
+	return nil
+}
+
+func (wc warningChecker) Evidence() string {
+	if ec, ok := wc.Checker.(EvidenceChecker); ok {
+		return ec.Evidence()
+	}
+	return ""
+}
+
+func (wc warningChecker) Config() map[string]interface{} {
+	if cc, ok := wc.Checker.(ConfigurableChecker); ok {
+		return cc.Config()
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openyurtio/openyurt/pkg/node-servant/components"
+)
+
+// fakeRuntime satisfies components.ContainerRuntimeForImage for composing checks, its methods must not be called.
+type fakeRuntime struct {
+	components.ContainerRuntimeForImage
+}
+
+func TestNewChecks(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       CheckConfig
+		expected  []string
+		expectErr bool
+	}{
+		{
+			name:     "default convert profile",
+			cfg:      CheckConfig{},
+			expected: []string{"DirExisting--etc-kubernetes", "DirExisting--var-lib-kubelet-pki", "Port-10268", "Port-10261", "Port-10267"},
+		},
+		{
+			name:     "root profile",
+			cfg:      CheckConfig{Profile: ProfileRoot},
+			expected: []string{"IsPrivilegedUser"},
+		},
+		{
+			name:     "images profile",
+			cfg:      CheckConfig{Profile: ProfileImages, Runtime: fakeRuntime{}, Images: []string{"pause:3.2"}},
+			expected: []string{"ImagePull"},
+		},
+		{
+			name:      "images profile without runtime",
+			cfg:       CheckConfig{Profile: ProfileImages},
+			expectErr: true,
+		},
+		{
+			name: "join profile",
+			cfg: CheckConfig{
+				Profile:          ProfileJoin,
+				Ports:            []int{10250},
+				RequiredFiles:    []string{"/etc/hosts"},
+				Services:         []ServiceSpec{{Name: "containerd", MustBeActive: true}},
+				Runtime:          fakeRuntime{},
+				Images:           []string{"pause:3.2"},
+				NodeName:         "edge-1",
+				Client:           fake.NewSimpleClientset(),
+				ClusterCIDRs:     []string{"10.244.0.0/16", "fd00::/108"},
+				NodeCIDRMaskSize: 24,
+				Hostname:         &CapturedHostname{},
+			},
+			expected: []string{"Hostname", "LeftoverState", "StaleKubeletMounts", "Swap", "FileExisting--etc-hosts", "RequiredServices", "Port-10250",
+				"IPFamily", "IPForward", "PodCIDRCapacity", "CRIStatus", "CRIVersion", "ImagePull", "HostnameServiceCollision", "HostnameUnchanged"},
+		},
+		{
+			name:      "unknown profile",
+			cfg:       CheckConfig{Profile: "upgrade"},
+			expectErr: true,
+		},
+		{
+			name:      "invalid port",
+			cfg:       CheckConfig{Ports: []int{70000}},
+			expectErr: true,
+		},
+		{
+			name:      "relative required file",
+			cfg:       CheckConfig{RequiredFiles: []string{"hosts"}},
+			expectErr: true,
+		},
+		{
+			name:      "service without name",
+			cfg:       CheckConfig{Services: []ServiceSpec{{MustBeActive: true}}},
+			expectErr: true,
+		},
+		{
+			name:      "images without runtime",
+			cfg:       CheckConfig{Images: []string{"pause:3.2"}},
+			expectErr: true,
+		},
+		{
+			name:      "node name without client",
+			cfg:       CheckConfig{NodeName: "edge-1"},
+			expectErr: true,
+		},
+		{
+			name:      "bad cidr",
+			cfg:       CheckConfig{ClusterCIDRs: []string{"10.244.0.0/33"}},
+			expectErr: true,
+		},
+		{
+			name:     "ipv6 node cidr mask size",
+			cfg:      CheckConfig{Profile: ProfileJoin, ClusterCIDRs: []string{"fd00::/48"}, NodeCIDRMaskSize: 64},
+			expected: []string{"LeftoverState", "StaleKubeletMounts", "Swap", "RequiredServices", "Port-10268", "Port-10261", "Port-10267", "IPFamily", "IPForward", "PodCIDRCapacity"},
+		},
+		{
+			name:      "ipv4 node cidr mask size too large",
+			cfg:       CheckConfig{ClusterCIDRs: []string{"10.244.0.0/16", "fd00::/48"}, NodeCIDRMaskSize: 64},
+			expectErr: true,
+		},
+		{
+			name:      "two cidrs of the same family",
+			cfg:       CheckConfig{ClusterCIDRs: []string{"10.244.0.0/16", "10.96.0.0/12"}},
+			expectErr: true,
+		},
+		{
+			name:      "severity of unknown check",
+			cfg:       CheckConfig{Severities: map[string]Severity{"Unknown": SeverityWarning}},
+			expectErr: true,
+		},
+		{
+			name:      "unknown severity",
+			cfg:       CheckConfig{Severities: map[string]Severity{"Port-10267": "fatal"}},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks, err := NewChecks(tt.cfg)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("expected error %v, got %v", tt.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+			var names []string
+			for _, c := range checks {
+				names = append(names, c.Name())
+			}
+			if !reflect.DeepEqual(names, tt.expected) {
+				t.Errorf("expected checks %v, got %v", tt.expected, names)
+			}
+		})
+	}
+}
+
+type fakeKubePaths struct{}
+
+func (fakeKubePaths) GetKubeadmConfPaths() []string {
+	return []string{"/usr/lib/systemd/system/kubelet.service.d/10-kubeadm.conf", "/etc/systemd/system/kubelet.service.d/10-kubeadm.conf"}
+}
+
+func (fakeKubePaths) GetKubeAdmFlagsEnvFile() string {
+	return "/var/lib/kubelet/kubeadm-flags.env"
+}
+
+// TestConvertProfile guards the checks run by RunConvertNodeChecks against drifting.
+func TestConvertProfile(t *testing.T) {
+	o := fakeKubePaths{}
+	expected := []Checker{
+		FileAtLeastOneExistingCheck{Paths: o.GetKubeadmConfPaths(), Label: "KubeadmConfig"},
+		FileExistingCheck{Path: o.GetKubeAdmFlagsEnvFile(), Label: "KubeAdmFlagsEnv"},
+		DirExistingCheck{Path: KubernetesDir},
+		DirExistingCheck{Path: KubeletPkiDir},
+		PortOpenCheck{port: YurtHubProxySecurePort},
+		PortOpenCheck{port: YurtHubProxyPort},
+		PortOpenCheck{port: YurtHubPort},
+		PortOpenCheck{port: YurttunnelAgentPort},
+	}
+
+	checks, err := NewChecks(CheckConfig{Profile: ProfileConvert, KubePaths: o, DeployTunnel: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(checks, expected) {
+		t.Errorf("expected checks %#v, got %#v", expected, checks)
+	}
+
+	checks, err = NewChecks(CheckConfig{Profile: ProfileConvert, KubePaths: o})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(checks, expected[:len(expected)-1]) {
+		t.Errorf("expected checks without the tunnel port %#v, got %#v", expected[:len(expected)-1], checks)
+	}
+}
+
+func TestJoinProfileServices(t *testing.T) {
+	conflict := ServiceSpec{Name: "kubelet", ConflictIfRunning: true}
+	containerd := ServiceSpec{Name: "containerd", MustBeActive: true}
+	tests := []struct {
+		name     string
+		cfg      CheckConfig
+		expected []ServiceSpec
+	}{
+		{
+			name:     "running kubelet conflicts with join",
+			cfg:      CheckConfig{Profile: ProfileJoin},
+			expected: []ServiceSpec{conflict},
+		},
+		{
+			name:     "configured services are kept",
+			cfg:      CheckConfig{Profile: ProfileJoin, Services: []ServiceSpec{containerd}},
+			expected: []ServiceSpec{conflict, containerd},
+		},
+		{
+			name:     "configured kubelet overrides the conflict",
+			cfg:      CheckConfig{Profile: ProfileJoin, Services: []ServiceSpec{{Name: "kubelet", MustBeActive: true}}},
+			expected: []ServiceSpec{{Name: "kubelet", MustBeActive: true}},
+		},
+		{
+			name:     "convert profile has no default services",
+			cfg:      CheckConfig{Profile: ProfileConvert, Services: []ServiceSpec{containerd}},
+			expected: []ServiceSpec{containerd},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks, err := NewChecks(tt.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var services []ServiceSpec
+			for _, c := range checks {
+				if rsc, ok := c.(RequiredServicesCheck); ok {
+					services = rsc.Services
+				}
+			}
+			if !reflect.DeepEqual(services, tt.expected) {
+				t.Errorf("expected services %v, got %v", tt.expected, services)
+			}
+		})
+	}
+}
+
+func TestWarningCheckerPassesThroughOptionalInterfaces(t *testing.T) {
+	checks, err := applySeverities([]Checker{
+		PortOpenCheck{port: YurtHubPort},
+		fakeDependentChecker{fakeChecker: fakeChecker{name: "Dependent"}, deps: []string{"Port-10267"}},
+		fakeChecker{name: "Plain"},
+	}, map[string]Severity{"Port-10267": SeverityWarning, "Dependent": SeverityWarning, "Plain": SeverityWarning})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if config := checks[0].(ConfigurableChecker).Config(); !reflect.DeepEqual(config, map[string]interface{}{"port": YurtHubPort}) {
+		t.Errorf("expected the config of the wrapped check, got %v", config)
+	}
+	if _, ok := checks[0].(EvidenceChecker); !ok {
+		t.Errorf("expected the wrapped check to keep its evidence")
+	}
+	if deps := checks[1].(DependentChecker).DependsOn(); !reflect.DeepEqual(deps, []string{"Port-10267"}) {
+		t.Errorf("expected the dependencies of the wrapped check, got %v", deps)
+	}
+	if deps := checks[2].(DependentChecker).DependsOn(); deps != nil {
+		t.Errorf("expected no dependencies, got %v", deps)
+	}
+	if config := checks[2].(ConfigurableChecker).Config(); config != nil {
+		t.Errorf("expected no config, got %v", config)
+	}
+}
+
+func TestApplySeverities(t *testing.T) {
+	checks, err := applySeverities([]Checker{
+		fakeChecker{name: "Fatal", errs: []error{errors.New("failed")}},
+		fakeChecker{name: "Demoted", errs: []error{errors.New("failed")}},
+	}, map[string]Severity{"Fatal": SeverityError, "Demoted": SeverityWarning})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if warnings, errs := checks[0].Check(); len(warnings) != 0 || len(errs) != 1 {
+		t.Errorf("expected Fatal to keep its error, got warnings %v, errors %v", warnings, errs)
+	}
+	if warnings, errs := checks[1].Check(); len(warnings) != 1 || len(errs) != 0 {
+		t.Errorf("expected Demoted to report its error as warning, got warnings %v, errors %v", warnings, errs)
+	}
+	if checks[1].Name() != "Demoted" {
+		t.Errorf("expected wrapped check to keep its name, got %s", checks[1].Name())
+	}
+}
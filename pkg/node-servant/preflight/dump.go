@@ -0,0 +1,55 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigurableChecker is implemented by checks that can report the parameters they run with.
+type ConfigurableChecker interface {
+	Checker
+	Config() map[string]interface{}
+}
+
+type checkConfigEntry struct {
+	Name   string                 `yaml:"name"`
+	Config map[string]interface{} `yaml:"config,omitempty"`
+}
+
+// DumpCheckConfig writes the names and parameters of checks to w as YAML, without running them.
+// Checks that don't implement ConfigurableChecker are written with their names only.
+func DumpCheckConfig(checks []Checker, w io.Writer) error {
+	entries := make([]checkConfigEntry, 0, len(checks))
+	for _, c := range checks {
+		entry := checkConfigEntry{Name: c.Name()}
+		if cc, ok := c.(ConfigurableChecker); ok {
+			entry.Config = cc.Config()
+		}
+		entries = append(entries, entry)
+	}
+
+	encoder := yaml.NewEncoder(w)
+	defer encoder.Close()
+	if err := encoder.Encode(entries); err != nil {
+		return errors.Wrap(err, "failed to encode check config")
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDumpCheckConfig(t *testing.T) {
+	checks := []Checker{
+		IsPrivilegedUserCheck{},
+		PortOpenCheck{port: YurtHubPort},
+		KernelCmdlineCheck{Forbidden: []string{"ipv6.disable=1"}},
+		FileExistingCheck{Path: "/etc/kubernetes/kubelet.conf"},
+		SysctlCheck{Sysctls: map[string]string{"net.ipv4.ip_forward": "1"}},
+		RequiredServicesCheck{Services: []ServiceSpec{{Name: "kubelet", MustBeActive: true}}},
+	}
+	expected := `- name: IsPrivilegedUser
+- name: Port-10267
+  config:
+    port: 10267
+- name: KernelCmdline
+  config:
+    forbidden:
+        - ipv6.disable=1
+    required: []
+- name: FileExisting--etc-kubernetes-kubelet.conf
+  config:
+    path: /etc/kubernetes/kubelet.conf
+- name: Sysctl
+  config:
+    requirePersistence: false
+    sysctls:
+        net.ipv4.ip_forward: "1"
+- name: RequiredServices
+  config:
+    services:
+        - name: kubelet
+          mustBeActive: true
+`
+
+	var buf bytes.Buffer
+	if err := DumpCheckConfig(checks, &buf); err != nil {
+		t.Fatalf("failed to dump check config: %v", err)
+	}
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// PreflightCheckFailedReason is the reason of the events recorded for failed preflight checks
+	PreflightCheckFailedReason = "PreflightCheckFailed"
+)
+
+// RecordFailureEvents emits a Warning event on object for every failed check carried by err,
+// err is expected to be returned by RunChecks. It returns the number of events recorded,
+// nothing is recorded if err is not a preflight Error.
+func RecordFailureEvents(err error, recorder record.EventRecorder, object runtime.Object) int {
+	var preflightErr *Error
+	if !errors.As(err, &preflightErr) {
+		return 0
+	}
+
+	for _, failure := range preflightErr.Failures {
+		msgs := make([]string, 0, len(failure.Errors))
+		for _, e := range failure.Errors {
+			msgs = append(msgs, e.Error())
+		}
+		recorder.Eventf(object, v1.EventTypeWarning, PreflightCheckFailedReason, "[%s]: %s", failure.Name, strings.Join(msgs, "; "))
+	}
+	return len(preflightErr.Failures)
+}
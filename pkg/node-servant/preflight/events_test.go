@@ -0,0 +1,68 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestRecordFailureEvents(t *testing.T) {
+	checks := []Checker{
+		fakeChecker{name: "Pass"},
+		fakeChecker{name: "Warn", warnings: []error{errors.New("warning")}},
+		fakeChecker{name: "FailA", errs: []error{errors.New("a1"), errors.New("a2")}},
+		fakeChecker{name: "FailB", errs: []error{errors.New("b")}},
+	}
+	err := RunChecks(checks, &bytes.Buffer{}, nil)
+	if err == nil {
+		t.Fatal("expected RunChecks to fail")
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+	if n := RecordFailureEvents(err, recorder, node); n != 2 {
+		t.Errorf("expected 2 events recorded, got %d", n)
+	}
+	close(recorder.Events)
+
+	expected := []string{
+		"Warning PreflightCheckFailed [FailA]: a1; a2",
+		"Warning PreflightCheckFailed [FailB]: b",
+	}
+	var events []string
+	for e := range recorder.Events {
+		events = append(events, e)
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected events %v, got %v", expected, events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("expected event %q, got %q", expected[i], events[i])
+		}
+	}
+
+	if n := RecordFailureEvents(errors.New("not a preflight error"), recorder, node); n != 0 {
+		t.Errorf("expected no event for a non preflight error, got %d", n)
+	}
+}
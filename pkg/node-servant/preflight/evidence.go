@@ -0,0 +1,62 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxEvidenceBytes bounds the evidence collected for a single check
+	maxEvidenceBytes = 4096
+
+	procNetTCP  = "/proc/net/tcp"
+	procNetTCP6 = "/proc/net/tcp6"
+	// tcpListenState is the st column of a listening socket in /proc/net/tcp
+	tcpListenState = "0A"
+)
+
+// EvidenceChecker is implemented by checks that can report the raw system state behind
+// their failures, e.g. the offending lines of a /proc file.
+type EvidenceChecker interface {
+	Checker
+	// Evidence returns the raw system state, or an empty string if it can't be collected.
+	Evidence() string
+}
+
+// collectEvidence returns the evidence of c truncated to at most maxEvidenceBytes, or an empty
+// string if c doesn't implement EvidenceChecker.
+func collectEvidence(c Checker) string {
+	ec, ok := c.(EvidenceChecker)
+	if !ok {
+		return ""
+	}
+	evidence := ec.Evidence()
+	if len(evidence) <= maxEvidenceBytes {
+		return evidence
+	}
+	// truncate on a line boundary, or on a rune boundary for a single long line
+	cut := strings.LastIndexByte(evidence[:maxEvidenceBytes], '\n')
+	if cut <= 0 {
+		cut = maxEvidenceBytes
+		for cut > 0 && !utf8.RuneStart(evidence[cut]) {
+			cut--
+		}
+	}
+	return evidence[:cut] + "\n... (truncated)"
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+type fakeEvidenceChecker struct {
+	fakeChecker
+	evidence string
+}
+
+func (fec fakeEvidenceChecker) Evidence() string {
+	return fec.evidence
+}
+
+func TestRunChecksWithEvidence(t *testing.T) {
+	checks := []Checker{
+		fakeEvidenceChecker{fakeChecker: fakeChecker{name: "Pass"}, evidence: "unused"},
+		fakeEvidenceChecker{fakeChecker: fakeChecker{name: "Fail", errs: []error{errors.New("failed")}}, evidence: "raw state"},
+		fakeEvidenceChecker{fakeChecker: fakeChecker{name: "Large", errs: []error{errors.New("failed")}}, evidence: strings.Repeat("x", maxEvidenceBytes+1)},
+		fakeChecker{name: "NoEvidence", errs: []error{errors.New("failed")}},
+	}
+
+	err := RunChecksWithEvidence(checks, io.Discard, sets.NewString(), nil)
+	var preflightErr *Error
+	if !errors.As(err, &preflightErr) {
+		t.Fatalf("expected preflight error, got %v", err)
+	}
+	evidence := map[string]string{}
+	for _, f := range preflightErr.Failures {
+		evidence[f.Name] = f.Evidence
+	}
+	expected := map[string]string{
+		"Fail":       "raw state",
+		"Large":      strings.Repeat("x", maxEvidenceBytes) + "\n... (truncated)",
+		"NoEvidence": "",
+	}
+	if !reflect.DeepEqual(evidence, expected) {
+		t.Errorf("expected evidence %v, got %v", expected, evidence)
+	}
+
+	err = RunChecks(checks, io.Discard, sets.NewString())
+	if errors.As(err, &preflightErr) && preflightErr.Failures[0].Evidence != "" {
+		t.Errorf("expected no evidence collected by RunChecks, got %q", preflightErr.Failures[0].Evidence)
+	}
+}
+
+func TestProcNetSocketLines(t *testing.T) {
+	content := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:2A03 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 12345 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:2A03 0100007F:D2F0 01 00000000:00000000 00:00000000 00000000     0        0 12346 1 0000000000000000 20 4 30 10 -1
+   2: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 12347 1 0000000000000000 100 0 0 10 0
+`
+	lines := procNetSocketLines(content, "tcp", 10755)
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], "0: 00000000:2A03") {
+		t.Errorf("expected the listening socket on port 10755, got %v", lines)
+	}
+}
+
+func TestCollectEvidenceTruncation(t *testing.T) {
+	line := strings.Repeat("x", 99) + "\n"
+	tests := []struct {
+		name     string
+		evidence string
+		expected string
+	}{
+		{
+			name:     "within limit",
+			evidence: "raw state",
+			expected: "raw state",
+		},
+		{
+			name:     "cut on line boundary",
+			evidence: strings.Repeat(line, maxEvidenceBytes/len(line)+1),
+			expected: strings.TrimSuffix(strings.Repeat(line, maxEvidenceBytes/len(line)), "\n") + "\n... (truncated)",
+		},
+		{
+			name:     "single line cut on rune boundary",
+			evidence: "x" + strings.Repeat("é", maxEvidenceBytes/2),
+			expected: "x" + strings.Repeat("é", maxEvidenceBytes/2-1) + "\n... (truncated)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evidence := collectEvidence(fakeEvidenceChecker{evidence: tt.evidence})
+			if evidence != tt.expected {
+				t.Errorf("expected %d bytes of evidence, got %d bytes", len(tt.expected), len(evidence))
+			}
+			if !utf8.ValidString(evidence) {
+				t.Errorf("expected valid utf-8 evidence")
+			}
+		})
+	}
+}
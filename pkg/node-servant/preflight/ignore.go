@@ -0,0 +1,52 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// LoadIgnoreSet reads the names of the checks whose errors should be ignored from a file,
+// so that the ignore list can be audited and version controlled. Names are separated by
+// newlines or commas, and everything after '#' on a line is a comment. Names are lowercased
+// to match how RunChecks looks them up.
+func LoadIgnoreSet(path string) (sets.String, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read ignore preflight errors file %s", path)
+	}
+	return parseIgnoreSet(string(content)), nil
+}
+
+func parseIgnoreSet(content string) sets.String {
+	ignored := sets.NewString()
+	for _, line := range strings.Split(content, "\n") {
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		for _, item := range strings.Split(line, ",") {
+			if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+				ignored.Insert(item)
+			}
+		}
+	}
+	return ignored
+}
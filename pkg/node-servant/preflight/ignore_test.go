@@ -0,0 +1,53 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadIgnoreSet(t *testing.T) {
+	content := `# checks ignored on edge nodes
+Port-10250
+KernelCmdline, VarLog # not relevant for SD card setups
+
+  ,TimeZoneDB,
+`
+	path := filepath.Join(t.TempDir(), "ignore")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write ignore file: %v", err)
+	}
+
+	ignored, err := LoadIgnoreSet(path)
+	if err != nil {
+		t.Fatalf("failed to load ignore set: %v", err)
+	}
+	expected := []string{"kernelcmdline", "port-10250", "timezonedb", "varlog"}
+	if !reflect.DeepEqual(ignored.List(), expected) {
+		t.Errorf("expected %v, got %v", expected, ignored.List())
+	}
+	if !setHasItemOrAll(ignored, "VarLog") {
+		t.Errorf("expected VarLog to be ignored")
+	}
+
+	if _, err := LoadIgnoreSet(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("expected error for missing file")
+	}
+}
@@ -26,6 +26,12 @@ type ImageOperator interface {
 	GetImagePullPolicy() v1.PullPolicy
 }
 
+// sandboxImageOperator is optionally implemented by an ImageOperator whose image list
+// contains the sandbox image of the container runtime.
+type sandboxImageOperator interface {
+	GetSandboxImage() string
+}
+
 type KubePathOperator interface {
 	GetKubeadmConfPaths() []string
 	GetKubeAdmFlagsEnvFile() string
@@ -0,0 +1,83 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"fmt"
+	"strings"
+)
+
+// remediationsByCode maps the reason codes of check errors to shell commands fixing them.
+var remediationsByCode = map[string]string{
+	ReasonPortInUse:              "ss -ltnp  # find the process holding the port and stop it",
+	ReasonImagePullFailed:        "crictl info && crictl images  # verify the runtime and the registry are reachable, then retry",
+	ReasonSandboxImagePullFailed: "crictl info | grep -A3 -i -e sandboxImage -e registry  # verify the registry auth and mirror config of the sandbox image",
+}
+
+// remediationsByName maps the names of checks to shell commands fixing them, for the checks
+// whose errors don't carry a reason code.
+var remediationsByName = map[string]string{
+	"Swap":               `swapoff -a && sed -i '/\sswap\s/ s/^#*/#/' /etc/fstab`,
+	"PKIPermissions":     `find /etc/kubernetes/pki -name '*.key' -exec chmod 600 {} +`,
+	"StaleKubeletMounts": `findmnt -rn -o TARGET | grep '^/var/lib/kubelet/' | sort -r | xargs -r umount`,
+	"TimeSyncStatus":     "systemctl restart chronyd && chronyc makestep",
+	"VethCreation":       "modprobe veth",
+}
+
+// GenerateRemediation returns a shell script with the commands fixing the failed checks in results,
+// which are usually the Failures of the Error returned by RunChecks. It's best effort: commands are
+// only emitted for the failures it recognizes, by reason code first and then by check name, others
+// are marked for manual investigation. The script is meant to be reviewed, it's never executed.
+func GenerateRemediation(results []CheckResult) string {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	b.WriteString("# Remediation for the failed preflight checks, review the commands before running them.\n")
+
+	for _, result := range results {
+		commands := remediationCommands(result)
+		if len(commands) == 0 {
+			fmt.Fprintf(&b, "\n# [%s]: manual investigation required\n", result.Name)
+			for _, err := range result.Errors {
+				fmt.Fprintf(&b, "#   %v\n", err)
+			}
+			continue
+		}
+		fmt.Fprintf(&b, "\n# [%s]\n", result.Name)
+		for _, command := range commands {
+			b.WriteString(command + "\n")
+		}
+	}
+	return b.String()
+}
+
+// remediationCommands returns the distinct commands known for the codes or the name of result.
+func remediationCommands(result CheckResult) []string {
+	var commands []string
+	seen := map[string]bool{}
+	for _, code := range result.Codes {
+		if command, ok := remediationsByCode[code]; ok && !seen[command] {
+			seen[command] = true
+			commands = append(commands, command)
+		}
+	}
+	if len(commands) == 0 {
+		if command, ok := remediationsByName[result.Name]; ok {
+			commands = append(commands, command)
+		}
+	}
+	return commands
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestGenerateRemediation(t *testing.T) {
+	results := []CheckResult{
+		{
+			Name: "Port-10267",
+			Errors: []error{
+				newCheckError(ReasonPortInUse, "Port %d is in use", 10267),
+			},
+			Codes: []string{ReasonPortInUse},
+		},
+		{
+			Name: "ImagePull",
+			Errors: []error{
+				newCheckError(ReasonSandboxImagePullFailed, "failed to pull sandbox image %s", "pause:3.2"),
+			},
+			Codes: []string{ReasonSandboxImagePullFailed},
+		},
+		{
+			Name:   "VethCreation",
+			Errors: []error{errors.New("node is not able to create veth pair")},
+		},
+		{
+			Name:   "Unknown",
+			Errors: []error{errors.New("something went wrong")},
+		},
+	}
+
+	script := GenerateRemediation(results)
+	expected := []string{
+		"#!/bin/sh\n",
+		"\n# [Port-10267]\n" + remediationsByCode[ReasonPortInUse] + "\n",
+		"\n# [ImagePull]\n" + remediationsByCode[ReasonSandboxImagePullFailed] + "\n",
+		"\n# [VethCreation]\nmodprobe veth\n",
+		"\n# [Unknown]: manual investigation required\n#   something went wrong\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(script, e) {
+			t.Errorf("expected script to contain %q, got:\n%s", e, script)
+		}
+	}
+}
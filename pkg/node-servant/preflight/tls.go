@@ -0,0 +1,82 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TLSOptions configures the CA used by connectivity checks to validate server certificates,
+// so that checks succeed against endpoints signed by internal CAs.
+// When neither CertPool nor CAFile is set, checks keep their default TLS behavior.
+type TLSOptions struct {
+	// CertPool is used as the root CAs when set.
+	CertPool *x509.CertPool
+	// CAFile is a PEM encoded CA bundle, it's used when CertPool is not set.
+	CAFile string
+}
+
+// IsSet returns true if a custom CA is configured.
+func (o TLSOptions) IsSet() bool {
+	return o.CertPool != nil || o.CAFile != ""
+}
+
+// rootCAs returns the configured cert pool, or nil if no custom CA is configured.
+func (o TLSOptions) rootCAs() (*x509.CertPool, error) {
+	if o.CertPool != nil {
+		return o.CertPool, nil
+	}
+	if o.CAFile == "" {
+		return nil, nil
+	}
+	pem, err := os.ReadFile(o.CAFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read CA file %s", o.CAFile)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.Errorf("no valid certificate found in CA file %s", o.CAFile)
+	}
+	return pool, nil
+}
+
+// newHTTPClient returns a http client validating server certificates against the custom CA
+// if configured. Otherwise certificates are validated against the system roots, or not
+// validated at all when insecureByDefault is set.
+func newHTTPClient(timeout time.Duration, opts TLSOptions, insecureByDefault bool) (*http.Client, error) {
+	pool, err := opts.rootCAs()
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{RootCAs: pool}
+	if pool == nil && insecureByDefault {
+		tlsConfig.InsecureSkipVerify = true
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+		},
+	}, nil
+}
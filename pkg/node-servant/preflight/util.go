@@ -0,0 +1,159 @@
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	procSysDir     = "/proc/sys"
+	procMountsPath = "/proc/mounts"
+	cgroupRootDir  = "/sys/fs/cgroup"
+	procMeminfo    = "/proc/meminfo"
+)
+
+// mountEntry is a line of /proc/mounts.
+type mountEntry struct {
+	Device     string
+	MountPoint string
+	FSType     string
+	Options    []string
+}
+
+// isDirWritable probes whether files can be created in dir by creating and removing a temporary file.
+func isDirWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".preflight-probe-")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
+
+// sysctlPath converts a sysctl name such as net.ipv4.ip_forward to its path under /proc/sys.
+func sysctlPath(name string) string {
+	return filepath.Join(procSysDir, strings.Replace(name, ".", "/", -1))
+}
+
+// readSysctl returns the trimmed value of the sysctl.
+func readSysctl(name string) (string, error) {
+	content, err := os.ReadFile(sysctlPath(name))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// readSysctlInt returns the value of the sysctl as an integer.
+func readSysctlInt(name string) (int, error) {
+	value, err := readSysctl(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
+
+// readMounts returns the mounts listed in /proc/mounts.
+func readMounts() ([]mountEntry, error) {
+	content, err := os.ReadFile(procMountsPath)
+	if err != nil {
+		return nil, err
+	}
+	return parseMounts(string(content)), nil
+}
+
+// parseMounts parses the content of /proc/mounts, unescaping the octal
+// sequences (e.g. \040 for space) the kernel uses in paths.
+func parseMounts(content string) []mountEntry {
+	var mounts []mountEntry
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		mounts = append(mounts, mountEntry{
+			Device:     unescapeMountPath(fields[0]),
+			MountPoint: unescapeMountPath(fields[1]),
+			FSType:     fields[2],
+			Options:    strings.Split(fields[3], ","),
+		})
+	}
+	return mounts
+}
+
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, "\\") {
+		return path
+	}
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			if c, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
+
+// isSubPath returns true if path is dir or is under dir.
+func isSubPath(path, dir string) bool {
+	dir = filepath.Clean(dir)
+	path = filepath.Clean(path)
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// isCgroupV2Unified returns true if the unified cgroup v2 hierarchy is mounted on /sys/fs/cgroup.
+func isCgroupV2Unified() bool {
+	_, err := os.Stat(filepath.Join(cgroupRootDir, "cgroup.controllers"))
+	return err == nil
+}
+
+// readMemTotal returns MemTotal of /proc/meminfo in bytes.
+func readMemTotal() (uint64, error) {
+	content, err := os.ReadFile(procMeminfo)
+	if err != nil {
+		return 0, err
+	}
+	return parseMemTotal(string(content))
+}
+
+// parseMemTotal parses the MemTotal line of /proc/meminfo, e.g. "MemTotal:  16315128 kB".
+func parseMemTotal(content string) (uint64, error) {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "MemTotal:" {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return kb * 1024, nil
+	}
+	return 0, errors.Errorf("MemTotal is not found in %s", procMeminfo)
+}
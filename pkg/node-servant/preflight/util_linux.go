@@ -0,0 +1,106 @@
+//go:build linux
+// +build linux
+
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// getAvailableBytes returns the bytes available to unprivileged users on the filesystem containing path.
+func getAvailableBytes(path string) (uint64, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
+
+// getTotalBytes returns the total size of the filesystem containing path.
+func getTotalBytes(path string) (uint64, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Blocks * uint64(stat.Bsize), nil
+}
+
+// createAndDeleteVeth creates a veth pair, confirms it appears and deletes it.
+func createAndDeleteVeth(name, peer string) error {
+	veth := &netlink.Veth{
+		LinkAttrs: netlink.LinkAttrs{Name: name},
+		PeerName:  peer,
+	}
+	if err := netlink.LinkAdd(veth); err != nil {
+		return errors.Wrapf(err, "failed to create veth pair %s/%s", name, peer)
+	}
+
+	for _, ifName := range []string{name, peer} {
+		if _, err := netlink.LinkByName(ifName); err != nil {
+			netlink.LinkDel(veth)
+			return errors.Wrapf(err, "veth %s doesn't appear after creation", ifName)
+		}
+	}
+	if err := netlink.LinkDel(veth); err != nil {
+		return errors.Wrapf(err, "failed to delete veth pair %s/%s", name, peer)
+	}
+	return nil
+}
+
+// fileOwner returns the uid of the owner of the file described by info.
+func fileOwner(info os.FileInfo) (int, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return int(stat.Uid), true
+}
+
+// createAndDeleteNetns creates a network namespace in a dedicated thread, which is never unlocked
+// so that it's terminated with the goroutine, and the namespace destroyed with it.
+func createAndDeleteNetns() error {
+	errCh := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		errCh <- unix.Unshare(unix.CLONE_NEWNET)
+	}()
+	return <-errCh
+}
+
+// isFileLocked returns true if a process holds a fcntl lock on path, as dpkg does on its lock files.
+// Locks held by the calling process are not reported.
+func isFileLocked(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	lock := unix.Flock_t{Type: unix.F_WRLCK}
+	if err := unix.FcntlFlock(f.Fd(), unix.F_GETLK, &lock); err != nil {
+		return false, err
+	}
+	return lock.Type != unix.F_UNLCK, nil
+}
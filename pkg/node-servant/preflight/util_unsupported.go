@@ -0,0 +1,49 @@
+//go:build !linux
+// +build !linux
+
+/*
+Copyright 2023 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preflight
+
+import (
+	"fmt"
+	"os"
+)
+
+func getAvailableBytes(path string) (uint64, error) {
+	return 0, fmt.Errorf("disk space check unsupported on this platform")
+}
+
+func getTotalBytes(path string) (uint64, error) {
+	return 0, fmt.Errorf("disk space check unsupported on this platform")
+}
+
+func createAndDeleteVeth(name, peer string) error {
+	return fmt.Errorf("veth creation unsupported on this platform")
+}
+
+func fileOwner(info os.FileInfo) (int, bool) {
+	return 0, false
+}
+
+func createAndDeleteNetns() error {
+	return fmt.Errorf("network namespace creation unsupported on this platform")
+}
+
+func isFileLocked(path string) (bool, error) {
+	return false, fmt.Errorf("file lock detection unsupported on this platform")
+}